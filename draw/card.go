@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// Arcana says whether a tarot card belongs to the major or minor arcana.
+// Cards from other card sets have no arcana.
+type Arcana string
+
+const (
+	ArcanaMajor Arcana = "major"
+	ArcanaMinor Arcana = "minor"
+)
+
+// Suit is the suit of a minor arcana or playing card. Major arcana and
+// Lenormand cards have no suit.
+type Suit string
+
+const (
+	SuitNone      Suit = ""
+	SuitCups      Suit = "cups"
+	SuitPentacles Suit = "pentacles"
+	SuitSwords    Suit = "swords"
+	SuitWands     Suit = "wands"
+
+	SuitClubs    Suit = "clubs"
+	SuitDiamonds Suit = "diamonds"
+	SuitHearts   Suit = "hearts"
+	SuitSpades   Suit = "spades"
+)
+
+// Card is a single card as drawn from a deck.
+//
+// ID is a stable slug such as "major-00-fool", "cups-14", "hearts-01" or
+// "lenormand-01-rider". Rank is 0-21 for the major arcana, 1-14 (Ace to King)
+// for the minor arcana, 1-13 for playing cards and 1-36 for Lenormand. Number and
+// NameSuit hold the display strings the frontend has always shown. Meaning is
+// only set when the request asks for interpretations, and Position only when
+// the cards are dealt into a spread.
+type Card struct {
+	ID       string
+	Arcana   Arcana
+	Suit     Suit
+	Rank     int
+	Number   string
+	NameSuit string
+	Reversed bool
+	Image    string
+	Meaning  *cardMeaning
+	Position *spreadPosition
+	// Copy numbers the deck a card came from when several copies of a deck
+	// are combined, and is 0 otherwise
+	Copy int
+}
+
+// cardJSON is the wire format of a Card. The number, nameSuit, reversed and
+// image fields are kept for the React frontend, which expects reversed to be
+// the display string "(Reversed)" or empty.
+type cardJSON struct {
+	ID         string          `json:"id"`
+	Arcana     Arcana          `json:"arcana,omitempty"`
+	Suit       Suit            `json:"suit,omitempty"`
+	Rank       int             `json:"rank"`
+	Number     string          `json:"number"`
+	NameSuit   string          `json:"nameSuit"`
+	Reversed   string          `json:"reversed"`
+	IsReversed bool            `json:"isReversed"`
+	Image      string          `json:"image"`
+	Meaning    *cardMeaning    `json:"meaning,omitempty"`
+	Position   *spreadPosition `json:"position,omitempty"`
+	Copy       int             `json:"copy,omitempty"`
+}
+
+const reversedLabel = "(Reversed)"
+
+func (c Card) MarshalJSON() ([]byte, error) {
+	out := cardJSON{
+		ID:         c.ID,
+		Arcana:     c.Arcana,
+		Suit:       c.Suit,
+		Rank:       c.Rank,
+		Number:     c.Number,
+		NameSuit:   c.NameSuit,
+		IsReversed: c.Reversed,
+		Image:      c.Image,
+		Meaning:    c.Meaning,
+		Position:   c.Position,
+		Copy:       c.Copy,
+	}
+	if c.Reversed {
+		out.Reversed = reversedLabel
+	}
+	return json.Marshal(out)
+}
+
+func (c *Card) UnmarshalJSON(data []byte) error {
+	var in cardJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*c = Card{
+		ID:       in.ID,
+		Arcana:   in.Arcana,
+		Suit:     in.Suit,
+		Rank:     in.Rank,
+		Number:   in.Number,
+		NameSuit: in.NameSuit,
+		Reversed: in.IsReversed || in.Reversed == reversedLabel,
+		Image:    in.Image,
+		Meaning:  in.Meaning,
+		Position: in.Position,
+		Copy:     in.Copy,
+	}
+	return nil
+}
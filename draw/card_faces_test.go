@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// The Lenormand and playing card faces in assets/images are drawn by
+// cardFace, so spread images can compose them; after changing a deck file
+// or the design, rewrite them with
+//
+//	go test -run TestCardFaces -update-card-faces
+var updateCardFaces = flag.Bool("update-card-faces", false, "rewrite the Lenormand and playing card faces in ../assets/images")
+
+// faceScale draws the faces at twice their 300x500 design size, so they stay
+// sharp in the frontend
+const faceScale = 2
+
+var (
+	faceInk    = color.RGBA{0x1a, 0x1a, 0x1a, 0xff}
+	faceRed    = color.RGBA{0xb3, 0x20, 0x2a, 0xff}
+	faceGold   = color.RGBA{0xb8, 0xa7, 0x7a, 0xff}
+	faceSubtle = color.RGBA{0x7a, 0x6a, 0x3a, 0xff}
+	faceEdge   = color.RGBA{0x3b, 0x3b, 0x3b, 0xff}
+	facePaper  = color.RGBA{0xfd, 0xfa, 0xf2, 0xff}
+)
+
+func TestCardFaces_UpToDate(t *testing.T) {
+	for _, setID := range []string{"lenormand", "playing-cards"} {
+		set := cardSets[setID]
+		for _, def := range set.Cards {
+			if path.Ext(def.Image) != ".png" {
+				t.Errorf("%s: expected a PNG face, got %s", def.ID, def.Image)
+				continue
+			}
+			face := cardFace(set, def)
+			file := "../assets/images/" + def.Image
+			if *updateCardFaces {
+				writeCardFace(t, file, face)
+				continue
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatalf("Failed to open %s: %v", file, err)
+			}
+			shipped, err := png.Decode(f)
+			f.Close()
+			if err != nil {
+				t.Fatalf("Failed to decode %s: %v", file, err)
+			}
+			if !sameImage(shipped, face) {
+				t.Errorf("%s is out of date; rewrite it with go test -run TestCardFaces -update-card-faces", file)
+			}
+		}
+	}
+}
+
+func writeCardFace(t *testing.T, file string, face *image.RGBA) {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", file, err)
+	}
+	defer f.Close()
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(f, face); err != nil {
+		t.Fatalf("Failed to write %s: %v", file, err)
+	}
+}
+
+// sameImage compares a decoded PNG with face as PNG stores it, without
+// premultiplied alpha
+func sameImage(a image.Image, face *image.RGBA) bool {
+	decoded, ok := a.(*image.NRGBA)
+	if !ok || decoded.Rect != face.Rect {
+		return false
+	}
+	for i := 0; i < len(face.Pix); i += 4 {
+		want := face.Pix[i : i+4]
+		// Only the smoothed corners of the card are partly transparent
+		if a := want[3]; a != 0 && a != 0xff {
+			c := color.NRGBAModel.Convert(color.RGBA{want[0], want[1], want[2], a}).(color.NRGBA)
+			want = []byte{c.R, c.G, c.B, c.A}
+		}
+		if !bytes.Equal(decoded.Pix[i:i+4], want) {
+			return false
+		}
+	}
+	return true
+}
+
+// cardFace draws def on a cream card with a gold inner border, its number
+// or rank and suit in opposite corners, and its title or suit in the middle
+func cardFace(set *cardSet, def cardDefinition) *image.RGBA {
+	face := image.NewRGBA(image.Rect(0, 0, 300*faceScale, 500*faceScale))
+	copy(face.Pix, blankFace().Pix)
+
+	ink := faceInk
+	if def.Suit == SuitHearts || def.Suit == SuitDiamonds {
+		ink = faceRed
+	}
+
+	if def.Suit == SuitNone {
+		trad := set.Traditions[set.DefaultTradition]
+		drawFaceText(face, strconv.Itoa(def.Rank), 44, 30, 4, ink)
+		title := trad.Titles[def.ID]
+		scale := 5
+		if captionWidth(captionText(title, 64), scale) > 250 {
+			scale = 4
+		}
+		drawFaceText(face, title, 150, 215, scale, ink)
+		drawFaceText(face, set.Name, 150, 285, 2, faceSubtle)
+	} else {
+		rank := faceRank(def.Rank)
+		drawFaceText(face, rank, 44, 30, 4, ink)
+		fillPip(face, def.Suit, 44, 82, 14, ink)
+		if slices.Contains(set.CourtRanks, def.Rank) {
+			drawFaceText(face, rank, 150, 170, 16, ink)
+			fillPip(face, def.Suit, 150, 345, 24, ink)
+		} else {
+			fillPip(face, def.Suit, 150, 250, 70, ink)
+		}
+	}
+
+	// The bottom right corner repeats the top left one, upside down
+	corner := image.Rect(20, 20, 80, 110)
+	for y := corner.Min.Y * faceScale; y < corner.Max.Y*faceScale; y++ {
+		for x := corner.Min.X * faceScale; x < corner.Max.X*faceScale; x++ {
+			face.SetRGBA(face.Rect.Dx()-1-x, face.Rect.Dy()-1-y, face.RGBAAt(x, y))
+		}
+	}
+	return face
+}
+
+// faceRank returns the index a playing card shows for rank
+func faceRank(rank int) string {
+	switch rank {
+	case 1:
+		return "A"
+	case 11:
+		return "J"
+	case 12:
+		return "Q"
+	case 13:
+		return "K"
+	}
+	return strconv.Itoa(rank)
+}
+
+// drawFaceText draws text centred on x with its top at y, in design units,
+// each font pixel scale design units square
+func drawFaceText(face *image.RGBA, text string, x, y, scale int, c color.RGBA) {
+	text = captionText(text, 64)
+	px := scale * faceScale
+	at := image.Pt(x*faceScale-captionWidth(text, px)/2, y*faceScale)
+	drawCaption(face, text, at, px, c)
+}
+
+// blankFace is the card every face is drawn on
+var blankFace = sync.OnceValue(func() *image.RGBA {
+	face := image.NewRGBA(image.Rect(0, 0, 300*faceScale, 500*faceScale))
+	card := image.Rect(0, 0, 300, 500)
+	fillShape(face, card, roundedRect(2, 2, 298, 498, 20), faceEdge)
+	fillShape(face, card, roundedRect(6, 6, 294, 494, 16), facePaper)
+	outer, inner := roundedRect(17, 17, 283, 483, 11), roundedRect(19, 19, 281, 481, 9)
+	fillShape(face, card, func(x, y float64) bool { return outer(x, y) && !inner(x, y) }, faceGold)
+	return face
+})
+
+// fillPip draws the symbol of suit centred on (cx, cy), size design units
+// from its centre to its edges
+func fillPip(face *image.RGBA, suit Suit, cx, cy, size int, c color.RGBA) {
+	bounds := image.Rect(cx-size, cy-size, cx+size+1, cy+size+1)
+	fillShape(face, bounds, pip(suit, float64(cx), float64(cy), float64(size)), c)
+}
+
+// fillShape blends c over the pixels of face inside shape, a test in design
+// units, within bounds, also in design units. Pixels whose corners are not
+// all inside or all outside the shape are sampled 4x4 times to smooth the
+// edges.
+func fillShape(face *image.RGBA, bounds image.Rectangle, inside func(x, y float64) bool, c color.RGBA) {
+	const samples = 4
+	area := image.Rect(bounds.Min.X*faceScale, bounds.Min.Y*faceScale, bounds.Max.X*faceScale, bounds.Max.Y*faceScale).Intersect(face.Rect)
+	w, h := area.Dx(), area.Dy()
+	corners := make([]bool, (w+1)*(h+1))
+	for j := 0; j <= h; j++ {
+		for i := 0; i <= w; i++ {
+			corners[j*(w+1)+i] = inside(float64(area.Min.X+i)/faceScale, float64(area.Min.Y+j)/faceScale)
+		}
+	}
+
+	for j := 0; j < h; j++ {
+		for i := 0; i < w; i++ {
+			px, py := area.Min.X+i, area.Min.Y+j
+			tl, tr := corners[j*(w+1)+i], corners[j*(w+1)+i+1]
+			bl, br := corners[(j+1)*(w+1)+i], corners[(j+1)*(w+1)+i+1]
+
+			a := 0.0
+			switch {
+			case tl && tr && bl && br:
+				a = 1
+			case !tl && !tr && !bl && !br:
+				continue
+			default:
+				hits := 0
+				for sy := 0; sy < samples; sy++ {
+					for sx := 0; sx < samples; sx++ {
+						x := (float64(px) + (float64(sx)+0.5)/samples) / faceScale
+						y := (float64(py) + (float64(sy)+0.5)/samples) / faceScale
+						if inside(x, y) {
+							hits++
+						}
+					}
+				}
+				a = float64(hits) / samples / samples
+			}
+
+			d := face.RGBAAt(px, py)
+			blend := func(dst, src uint8) uint8 {
+				return uint8(math.Round(float64(dst)*(1-a) + float64(src)*a))
+			}
+			face.SetRGBA(px, py, color.RGBA{blend(d.R, c.R), blend(d.G, c.G), blend(d.B, c.B), blend(d.A, c.A)})
+		}
+	}
+}
+
+// roundedRect returns a test for the rectangle from (x0, y0) to (x1, y1)
+// with corners of radius r
+func roundedRect(x0, y0, x1, y1, r float64) func(x, y float64) bool {
+	return func(x, y float64) bool {
+		if x < x0 || x > x1 || y < y0 || y > y1 {
+			return false
+		}
+		cx := math.Max(x0+r, math.Min(x, x1-r))
+		cy := math.Max(y0+r, math.Min(y, y1-r))
+		return (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r
+	}
+}
+
+// pip returns a test for the symbol of suit centred on (cx, cy)
+func pip(suit Suit, cx, cy, size float64) func(x, y float64) bool {
+	circle := func(u, v, ox, oy, r float64) bool { return (u-ox)*(u-ox)+(v-oy)*(v-oy) <= r*r }
+	heart := func(u, v float64) bool {
+		if circle(u, v, -0.5, -0.4, 0.5) || circle(u, v, 0.5, -0.4, 0.5) {
+			return true
+		}
+		return v >= -0.4 && v <= 1 && math.Abs(u) <= (1-v)/1.4
+	}
+	stem := func(u, v float64) bool { return v >= 0.1 && v <= 1 && math.Abs(u) <= 0.06+0.3*(v-0.1) }
+
+	return func(x, y float64) bool {
+		u, v := (x-cx)/size, (y-cy)/size
+		switch suit {
+		case SuitHearts:
+			return heart(u, v)
+		case SuitDiamonds:
+			return math.Abs(u)/0.75+math.Abs(v) <= 1
+		case SuitSpades:
+			return heart(u/0.9, -(v+0.2)/0.8) || stem(u, v)
+		default:
+			return circle(u, v, 0, -0.55, 0.4) || circle(u, v, -0.5, 0.05, 0.4) ||
+				circle(u, v, 0.5, 0.05, 0.4) || circle(u, v, 0, -0.1, 0.3) || stem(u, v)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cardImageSource opens the image files named by Card.Image
+type cardImageSource interface {
+	Open(name string) (io.ReadCloser, error)
+}
+
+// dirImageSource reads card images from a directory, such as assets/images
+type dirImageSource string
+
+func (d dirImageSource) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.Base(name)))
+}
+
+// urlImageSource fetches card images over HTTP, from the CloudFront
+// distribution that also serves them to the frontend
+type urlImageSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (u urlImageSource) Open(name string) (io.ReadCloser, error) {
+	resp, err := u.client.Get(u.baseURL + "/" + path.Base(name))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s: %s", name, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// loadCardImageSource picks where card images are read from: CARD_IMAGE_DIR
+// when it is set, e.g. for images packaged with the function, and the
+// CloudFront images otherwise
+func loadCardImageSource() cardImageSource {
+	if dir := os.Getenv("CARD_IMAGE_DIR"); dir != "" {
+		return dirImageSource(dir)
+	}
+	return urlImageSource{
+		baseURL: os.Getenv("CLOUDFRONT_URL") + "/images",
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+const (
+	// maxCardWidth is the widest a card can be drawn, and the width source
+	// images are reduced to before they are cached
+	maxCardWidth = 300
+	// maxCachedImages bounds the decoded images kept across warm invocations,
+	// enough for every card of the larger spreads
+	maxCachedImages = 32
+)
+
+var errUnsupportedImage = errors.New("card image is not a JPEG or PNG")
+
+// cardImages decodes and caches card images for the life of the container
+var cardImages = newCardImageCache(loadCardImageSource())
+
+// cardImageCache keeps the most recently used card images, decoded and
+// reduced to maxCardWidth, so warm invocations skip fetching and decoding
+type cardImageCache struct {
+	mu      sync.Mutex
+	source  cardImageSource
+	order   *list.List // of *cachedImage, most recently used first
+	entries map[string]*list.Element
+}
+
+type cachedImage struct {
+	name string
+	img  *image.RGBA
+}
+
+func newCardImageCache(source cardImageSource) *cardImageCache {
+	return &cardImageCache{
+		source:  source,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// get returns the named card image at maxCardWidth. Only raster images can
+// be composed, so images in any other format, such as SVG, return
+// errUnsupportedImage.
+func (c *cardImageCache) get(name string) (*image.RGBA, error) {
+	if ext := strings.ToLower(path.Ext(name)); ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		return nil, errUnsupportedImage
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[name]; ok {
+		c.order.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*cachedImage).img, nil
+	}
+	c.mu.Unlock()
+
+	// Decode outside the lock so a slow fetch does not hold up other cards.
+	// Two requests may both decode a missing image; the second copy wins.
+	img, err := c.decode(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[name]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[name] = c.order.PushFront(&cachedImage{name: name, img: img})
+	for c.order.Len() > maxCachedImages {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedImage).name)
+	}
+	return img, nil
+}
+
+func (c *cardImageCache) decode(name string) (*image.RGBA, error) {
+	r, err := c.source.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	src, _, err := image.Decode(r)
+	if errors.Is(err, image.ErrFormat) {
+		return nil, errUnsupportedImage
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", name, err)
+	}
+	return scaleImage(src, maxCardWidth), nil
+}
+
+// scaleImage reduces src to width pixels wide, keeping its aspect ratio, by
+// averaging the source pixels that fall in each target pixel. Images that
+// are already narrow enough are only copied.
+func scaleImage(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	if b.Dx() <= width {
+		dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(dst, dst.Rect, src, b.Min, draw.Src)
+		return dst
+	}
+	height := max(1, int(math.Round(float64(b.Dy())*float64(width)/float64(b.Dx()))))
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	// Average in the source's own color model where it can be read
+	// directly, as decoded JPEGs are YCbCr, and through At otherwise
+	ycc, isYCbCr := src.(*image.YCbCr)
+	for y := 0; y < height; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/height, b.Min.Y+(y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/width, b.Min.X+(x+1)*b.Dx()/width
+			var s [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					if isYCbCr {
+						s[0] += uint64(ycc.Y[ycc.YOffset(sx, sy)])
+						ci := ycc.COffset(sx, sy)
+						s[1] += uint64(ycc.Cb[ci])
+						s[2] += uint64(ycc.Cr[ci])
+						continue
+					}
+					r, g, bl, a := src.At(sx, sy).RGBA()
+					s[0], s[1], s[2], s[3] = s[0]+uint64(r>>8), s[1]+uint64(g>>8), s[2]+uint64(bl>>8), s[3]+uint64(a>>8)
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			if isYCbCr {
+				r, g, bl := color.YCbCrToRGB(uint8(s[0]/n), uint8(s[1]/n), uint8(s[2]/n))
+				dst.SetRGBA(x, y, color.RGBA{r, g, bl, 0xff})
+				continue
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(s[0] / n), uint8(s[1] / n), uint8(s[2] / n), uint8(s[3] / n)})
+		}
+	}
+	return dst
+}
+
+// rotateImage turns src clockwise by degrees about its centre onto a canvas
+// just large enough to hold it. Quarter turns move pixels exactly; other
+// angles pick the nearest source pixel and leave the corners transparent.
+func rotateImage(src *image.RGBA, degrees int) *image.RGBA {
+	degrees = ((degrees % 360) + 360) % 360
+	if degrees == 0 {
+		return src
+	}
+
+	rad := float64(degrees) * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	if degrees%90 == 0 {
+		sin, cos = math.Round(sin), math.Round(cos)
+	}
+	w, h := float64(src.Rect.Dx()), float64(src.Rect.Dy())
+	dw := int(math.Round(math.Abs(w*cos) + math.Abs(h*sin)))
+	dh := int(math.Round(math.Abs(w*sin) + math.Abs(h*cos)))
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	// Map the centre of each target pixel back into the source
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			cx, cy := float64(x)+0.5-float64(dw)/2, float64(y)+0.5-float64(dh)/2
+			sx := int(math.Floor(cx*cos + cy*sin + w/2))
+			sy := int(math.Floor(-cx*sin + cy*cos + h/2))
+			if sx >= 0 && sx < src.Rect.Dx() && sy >= 0 && sy < src.Rect.Dy() {
+				dst.SetRGBA(x, y, src.RGBAAt(src.Rect.Min.X+sx, src.Rect.Min.Y+sy))
+			}
+		}
+	}
+	return dst
+}
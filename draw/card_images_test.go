@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"testing"
+)
+
+var (
+	testTop    = color.RGBA{0xff, 0, 0, 0xff}
+	testBottom = color.RGBA{0, 0, 0xff, 0xff}
+)
+
+// testImageSource serves every card as a 100x160 PNG, red above its middle
+// and blue below so a reversed card can be told apart, and counts the images
+// it opens
+type testImageSource struct {
+	opens map[string]int
+}
+
+func (s *testImageSource) Open(name string) (io.ReadCloser, error) {
+	s.opens[name]++
+	img := image.NewRGBA(image.Rect(0, 0, 100, 160))
+	for y := 0; y < 160; y++ {
+		for x := 0; x < 100; x++ {
+			if y < 80 {
+				img.SetRGBA(x, y, testTop)
+			} else {
+				img.SetRGBA(x, y, testBottom)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return io.NopCloser(&buf), nil
+}
+
+// useTestCardImages swaps the card image cache for one reading from a
+// testImageSource until the test ends
+func useTestCardImages(t *testing.T) *testImageSource {
+	source := &testImageSource{opens: map[string]int{}}
+	saved := cardImages
+	cardImages = newCardImageCache(source)
+	t.Cleanup(func() { cardImages = saved })
+	return source
+}
+
+func TestCardImageCache(t *testing.T) {
+	source := &testImageSource{opens: map[string]int{}}
+	cache := newCardImageCache(source)
+
+	first, err := cache.get("m00.jpg")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	again, _ := cache.get("m00.jpg")
+	if again != first || source.opens["m00.jpg"] != 1 {
+		t.Errorf("Expected a cached image after 1 open, got %d opens", source.opens["m00.jpg"])
+	}
+
+	// Filling the cache evicts the least recently used image
+	for i := 0; i < maxCachedImages; i++ {
+		cache.get("c" + strconv.Itoa(i) + ".png")
+	}
+	cache.get("m00.jpg")
+	if source.opens["m00.jpg"] != 2 || source.opens["c0.png"] != 1 {
+		t.Errorf("Expected m00.jpg to be evicted and opened again, got %v", source.opens)
+	}
+	if len(cache.entries) != maxCachedImages {
+		t.Errorf("Expected %d cached images, got %d", maxCachedImages, len(cache.entries))
+	}
+
+	if _, err := cache.get("Clubs01.svg"); !errors.Is(err, errUnsupportedImage) {
+		t.Errorf("Expected errUnsupportedImage, got %v", err)
+	}
+	if source.opens["Clubs01.svg"] != 0 {
+		t.Error("Expected an SVG image not to be opened")
+	}
+}
+
+func TestCardImageCache_Assets(t *testing.T) {
+	cache := newCardImageCache(dirImageSource("../assets/images"))
+	img, err := cache.get("Cups01.jpg")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The assets are about 1112x1920, reduced to maxCardWidth
+	if img.Rect.Dx() != maxCardWidth || img.Rect.Dy() < 500 || img.Rect.Dy() > 540 {
+		t.Errorf("Expected a %d pixel wide card, got %v", maxCardWidth, img.Rect)
+	}
+	if _, err := cache.get("missing.jpg"); err == nil || errors.Is(err, errUnsupportedImage) {
+		t.Errorf("Expected an error opening a missing image, got %v", err)
+	}
+}
+
+func TestScaleImage(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 40, 80))
+	ycc := image.NewYCbCr(image.Rect(0, 0, 40, 80), image.YCbCrSubsampleRatio420)
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 40; x++ {
+			rgba.SetRGBA(x, y, color.RGBA{200, 100, 50, 0xff})
+		}
+	}
+	cy, cb, cr := color.RGBToYCbCr(200, 100, 50)
+	for i := range ycc.Y {
+		ycc.Y[i] = cy
+	}
+	for i := range ycc.Cb {
+		ycc.Cb[i], ycc.Cr[i] = cb, cr
+	}
+
+	for _, src := range []image.Image{rgba, ycc} {
+		dst := scaleImage(src, 10)
+		if dst.Rect != image.Rect(0, 0, 10, 20) {
+			t.Fatalf("Expected a 10x20 image, got %v", dst.Rect)
+		}
+		got := dst.RGBAAt(5, 10)
+		if absDiff(got.R, 200) > 2 || absDiff(got.G, 100) > 2 || absDiff(got.B, 50) > 2 {
+			t.Errorf("%T: expected the color to be kept, got %v", src, got)
+		}
+	}
+
+	if dst := scaleImage(rgba, 60); dst.Rect != image.Rect(0, 0, 40, 80) {
+		t.Errorf("Expected a narrow image to be kept at 40x80, got %v", dst.Rect)
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestRotateImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 2; x++ {
+			src.SetRGBA(x, y, color.RGBA{uint8(x), uint8(y), 0, 0xff})
+		}
+	}
+
+	if rotateImage(src, 360) != src {
+		t.Error("Expected a full turn to return the image unchanged")
+	}
+
+	half := rotateImage(src, 180)
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 2; x++ {
+			if half.RGBAAt(x, y) != src.RGBAAt(1-x, 2-y) {
+				t.Errorf("180°: expected pixel %d,%d to be %v, got %v", x, y, src.RGBAAt(1-x, 2-y), half.RGBAAt(x, y))
+			}
+		}
+	}
+
+	// A clockwise quarter turn brings the bottom left corner to the top left
+	quarter := rotateImage(src, 90)
+	if quarter.Rect != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("Expected a 3x2 image, got %v", quarter.Rect)
+	}
+	if quarter.RGBAAt(0, 0) != src.RGBAAt(0, 2) || quarter.RGBAAt(2, 1) != src.RGBAAt(1, 0) {
+		t.Errorf("90°: unexpected corners %v and %v", quarter.RGBAAt(0, 0), quarter.RGBAAt(2, 1))
+	}
+	if back := rotateImage(rotateImage(quarter, 90), 180); !bytes.Equal(back.Pix, src.Pix) {
+		t.Error("Expected four quarter turns to restore the image")
+	}
+}
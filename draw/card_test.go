@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+func TestCards_StableIDs(t *testing.T) {
+	idPattern := regexp.MustCompile(`^(major-\d\d-[a-z-]+|(cups|pentacles|swords|wands)-\d\d)$`)
+
+	seen := map[string]bool{}
+	for _, card := range append(tarotDeck("tarot-major", "rws"), tarotDeck("tarot-minor", "rws")...) {
+		if !idPattern.MatchString(card.ID) {
+			t.Errorf("Card %s %s has malformed ID '%s'", card.Number, card.NameSuit, card.ID)
+		}
+		if seen[card.ID] {
+			t.Errorf("Duplicate card ID '%s'", card.ID)
+		}
+		seen[card.ID] = true
+	}
+
+	if len(seen) != 78 {
+		t.Errorf("Expected 78 unique IDs, got %d", len(seen))
+	}
+}
+
+func TestCards_TypedFields(t *testing.T) {
+	for _, card := range tarotDeck("tarot-major", "rws") {
+		if card.Arcana != ArcanaMajor || card.Suit != SuitNone || card.Rank < 0 || card.Rank > 21 {
+			t.Errorf("Unexpected major arcana card %+v", card)
+		}
+	}
+	if fool := tarotDeck("tarot-major", "rws")[0]; fool.ID != "major-00-fool" || fool.Rank != 0 {
+		t.Errorf("Expected The Fool first with rank 0, got %+v", fool)
+	}
+
+	for _, card := range tarotDeck("tarot-minor", "rws") {
+		if card.Arcana != ArcanaMinor || card.Suit == SuitNone || card.Rank < 1 || card.Rank > 14 {
+			t.Errorf("Unexpected minor arcana card %+v", card)
+		}
+	}
+}
+
+func TestCard_JSONKeepsLegacyFields(t *testing.T) {
+	card := Card{
+		ID:       "cups-14",
+		Arcana:   ArcanaMinor,
+		Suit:     SuitCups,
+		Rank:     14,
+		Number:   "King",
+		NameSuit: "of Cups",
+		Reversed: true,
+		Image:    "Cups14.jpg",
+	}
+
+	body, err := json.Marshal(card)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatalf("Failed to parse card JSON: %v", err)
+	}
+	if fields["number"] != "King" || fields["nameSuit"] != "of Cups" || fields["image"] != "Cups14.jpg" {
+		t.Errorf("Expected legacy display fields, got %s", body)
+	}
+	if fields["reversed"] != "(Reversed)" || fields["isReversed"] != true {
+		t.Errorf("Expected legacy and boolean orientation, got %s", body)
+	}
+
+	var decoded Card
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("Failed to decode card: %v", err)
+	}
+	if decoded != card {
+		t.Errorf("Expected round trip to give %+v, got %+v", card, decoded)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// cardsResponse lists the cards of one deck in the order getDeck builds them
+type cardsResponse struct {
+	Deck      string `json:"deck"`
+	Tradition string `json:"tradition"`
+	Cards     []Card `json:"cards"`
+	Locale    string `json:"locale"`
+}
+
+// cardResponse describes a single card of the catalog
+type cardResponse struct {
+	Card      Card   `json:"card"`
+	CardSet   string `json:"cardSet"`
+	Tradition string `json:"tradition"`
+	Locale    string `json:"locale"`
+}
+
+// deckSummary describes a deck that can be drawn from. Clients pass its ID,
+// or one of its aliases, as deckSize.
+type deckSummary struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Aliases          []string `json:"aliases"`
+	CardSet          string   `json:"cardSet"`
+	Cards            int      `json:"cards"`
+	Traditions       []string `json:"traditions"`
+	DefaultTradition string   `json:"defaultTradition"`
+}
+
+type decksResponse struct {
+	Decks []deckSummary `json:"decks"`
+}
+
+type spreadsResponse struct {
+	Spreads []*spread `json:"spreads"`
+}
+
+var errCardNotFound = errors.New("card not found")
+
+// defaultCatalogDeck is the deck GET /cards lists when no deck is given
+const defaultCatalogDeck = "tarot-full"
+
+// sortedCardSets returns the card sets ordered by ID, so listings are stable
+func sortedCardSets() []*cardSet {
+	sets := make([]*cardSet, 0, len(cardSets))
+	for _, set := range cardSets {
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].ID < sets[j].ID })
+	return sets
+}
+
+// findCard returns the card with the given ID, named by the named tradition
+// of its card set, or the set's default tradition when tradName is empty
+func findCard(id, tradName string) (Card, *cardSet, *tradition, error) {
+	for _, set := range sortedCardSets() {
+		for _, deck := range set.Decks {
+			if !slices.ContainsFunc(deck.definitions(), func(def cardDefinition) bool { return def.ID == id }) {
+				continue
+			}
+			trad, ok := set.lookupTradition(tradName)
+			if !ok {
+				return Card{}, nil, nil, errUnknownTradition
+			}
+			for _, card := range deck.cards(trad) {
+				if card.ID == id {
+					return card, set, trad, nil
+				}
+			}
+		}
+	}
+	return Card{}, nil, nil, errCardNotFound
+}
+
+// catalogCards prepares catalog cards named by trad to show, as draws do:
+// image URLs on CloudFront, names in the locale and meanings attached if
+// asked for
+func catalogCards(cards []Card, trad *tradition, loc localizer, includeMeanings bool) {
+	for i := range cards {
+		cards[i].Image = cloudFrontURL + "/images/" + cards[i].Image
+	}
+	loc.localizeCards(cards, trad.Name)
+	if includeMeanings {
+		attachMeanings(cards)
+	}
+}
+
+// cardsHandler serves GET /cards and GET /cards/{id}. The deck (an ID or
+// alias, the full tarot deck by default), tradition, locale and
+// includeMeanings=true may be given as query parameters.
+func cardsHandler(req events.APIGatewayV2HTTPRequest, path string) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "GET" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_get_only"))
+	}
+
+	query := req.QueryStringParameters
+	if locale := query["locale"]; locale != "" {
+		loc = newLocalizer(locale)
+	}
+	includeMeanings := query["includeMeanings"] == "true"
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		id = strings.TrimPrefix(strings.TrimPrefix(path, "/cards"), "/")
+	}
+	if id != "" {
+		card, set, trad, err := findCard(id, query["tradition"])
+		switch err {
+		case nil:
+		case errCardNotFound:
+			return errorResult(http.StatusNotFound, "card_not_found", loc.message("card_not_found"))
+		default:
+			return dealErrorResult(loc, err)
+		}
+
+		cards := []Card{card}
+		catalogCards(cards, trad, loc, includeMeanings)
+		return jsonResponse(http.StatusOK, cardResponse{
+			Card:      cards[0],
+			CardSet:   set.ID,
+			Tradition: trad.Name,
+			Locale:    loc.locale,
+		})
+	}
+
+	deckID := query["deck"]
+	if deckID == "" {
+		deckID = defaultCatalogDeck
+	}
+	deck, trad, err := resolveDeck(deckID, query["tradition"])
+	if err != nil {
+		return dealErrorResult(loc, err)
+	}
+
+	cards := deck.cards(trad)
+	catalogCards(cards, trad, loc, includeMeanings)
+	return jsonResponse(http.StatusOK, cardsResponse{
+		Deck:      deck.ID,
+		Tradition: trad.Name,
+		Cards:     cards,
+		Locale:    loc.locale,
+	})
+}
+
+// decksHandler serves GET /decks, listing every deck of every card set
+func decksHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "GET" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_get_only"))
+	}
+
+	decks := []deckSummary{}
+	for _, set := range sortedCardSets() {
+		traditions := make([]string, 0, len(set.Traditions))
+		for name := range set.Traditions {
+			traditions = append(traditions, name)
+		}
+		sort.Strings(traditions)
+
+		for _, deck := range set.Decks {
+			aliases := deck.Aliases
+			if aliases == nil {
+				aliases = []string{}
+			}
+			decks = append(decks, deckSummary{
+				ID:               deck.ID,
+				Name:             deck.Name,
+				Aliases:          aliases,
+				CardSet:          set.ID,
+				Cards:            len(deck.definitions()),
+				Traditions:       traditions,
+				DefaultTradition: set.DefaultTradition,
+			})
+		}
+	}
+	return jsonResponse(http.StatusOK, decksResponse{Decks: decks})
+}
+
+// spreadsHandler serves GET /spreads, listing the spread catalog
+func spreadsHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "GET" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_get_only"))
+	}
+	return jsonResponse(http.StatusOK, spreadsResponse{Spreads: spreads})
+}
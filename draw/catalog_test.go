@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCardsHandler_Deck(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var cardsResp cardsResponse
+	call(t, apiRequest("GET", "/cards", "", nil), 200, &cardsResp)
+	if cardsResp.Deck != "tarot-full" || len(cardsResp.Cards) != 78 {
+		t.Fatalf("Expected the 78 cards of tarot-full, got %d cards of %s", len(cardsResp.Cards), cardsResp.Deck)
+	}
+	for _, card := range cardsResp.Cards {
+		if card.Reversed {
+			t.Errorf("Expected catalog cards upright, got %s reversed", card.ID)
+		}
+		if !strings.HasPrefix(card.Image, "https://test.cloudfront.net/images/") {
+			t.Errorf("Expected a full image URL, got %s", card.Image)
+		}
+	}
+
+	cardsResp = cardsResponse{}
+	call(t, apiRequest("GET", "/cards/", "", map[string]string{"deck": "Major Arcana only", "tradition": "thoth", "locale": "fr"}), 200, &cardsResp)
+	if cardsResp.Deck != "tarot-major" || cardsResp.Tradition != "thoth" || cardsResp.Locale != "fr" || len(cardsResp.Cards) != 22 {
+		t.Errorf("Expected 22 thoth cards of tarot-major in fr, got %d %s cards of %s in %s",
+			len(cardsResp.Cards), cardsResp.Tradition, cardsResp.Deck, cardsResp.Locale)
+	}
+}
+
+func TestCardsHandler_Card(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var cardResp cardResponse
+	call(t, apiRequest("GET", "/cards/cups-14", "", map[string]string{"includeMeanings": "true"}), 200, &cardResp)
+	if cardResp.Card.ID != "cups-14" || cardResp.CardSet != "tarot" || cardResp.Card.Meaning == nil {
+		t.Errorf("Expected cups-14 of tarot with its meaning, got %+v", cardResp)
+	}
+	if cardResp.Card.Image != "https://test.cloudfront.net/images/"+cardImage(t, "cups-14") {
+		t.Errorf("Expected a full image URL, got %s", cardResp.Card.Image)
+	}
+
+	// API Gateway events routed as "GET /cards/{id}" carry the ID as a path
+	// parameter
+	req := apiRequest("GET", "", "", map[string]string{"tradition": "standard"})
+	req.RouteKey = "GET /cards/{id}"
+	req.PathParameters = map[string]string{"id": "hearts-01"}
+	cardResp = cardResponse{}
+	call(t, req, 200, &cardResp)
+	if cardResp.Card.ID != "hearts-01" || cardResp.CardSet != "playing-cards" {
+		t.Errorf("Expected hearts-01 of playing-cards, got %+v", cardResp)
+	}
+}
+
+// cardImage returns the image file of the card with the given ID
+func cardImage(t *testing.T, id string) string {
+	t.Helper()
+	card, _, _, err := findCard(id, "")
+	if err != nil {
+		t.Fatalf("Expected card %s, got %v", id, err)
+	}
+	return card.Image
+}
+
+func TestCardsHandler_Errors(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	cases := []struct {
+		path   string
+		query  map[string]string
+		status int
+		code   string
+	}{
+		{"/cards/cups-15", nil, 404, "card_not_found"},
+		{"/cards/cups-14", map[string]string{"tradition": "standard"}, 400, "invalid_tradition"},
+		{"/cards", map[string]string{"deck": "Tiny Deck"}, 400, "invalid_deck_options"},
+	}
+	for _, c := range cases {
+		var errorResp errorResponse
+		call(t, apiRequest("GET", c.path, "", c.query), c.status, &errorResp)
+		if errorResp.Error != c.code {
+			t.Errorf("%s %v: expected %s, got %s", c.path, c.query, c.code, errorResp.Error)
+		}
+	}
+
+	req := apiRequest("GET", "/cards", "", nil)
+	req.RequestContext.HTTP.Method = "POST"
+	call(t, req, 405, nil)
+}
+
+func TestDecksHandler(t *testing.T) {
+	var decksResp decksResponse
+	call(t, apiRequest("GET", "/decks", "", nil), 200, &decksResp)
+
+	found := map[string]deckSummary{}
+	for _, deck := range decksResp.Decks {
+		found[deck.ID] = deck
+	}
+	if len(found) != len(decksResp.Decks) {
+		t.Errorf("Expected unique deck IDs, got %d decks and %d IDs", len(decksResp.Decks), len(found))
+	}
+	expected := map[string]int{"tarot-full": 78, "tarot-major": 22, "tarot-minor": 56, "lenormand": 36, "playing-52": 52}
+	for id, count := range expected {
+		if found[id].Cards != count {
+			t.Errorf("Expected %s to have %d cards, got %d", id, count, found[id].Cards)
+		}
+	}
+	if full := found["tarot-full"]; full.DefaultTradition != "rws" || len(full.Traditions) != 3 || full.Aliases[0] != "Full Deck" {
+		t.Errorf("Expected tarot-full with alias Full Deck and three traditions defaulting to rws, got %+v", full)
+	}
+
+	// Every listed deck can be drawn from by its ID
+	for id := range found {
+		if _, _, err := getDeck(id, "Upright only", "", nil, nil, defaultSource); err != nil {
+			t.Errorf("Expected deck %s to be drawable, got %v", id, err)
+		}
+	}
+}
+
+func TestSpreadsHandler(t *testing.T) {
+	var spreadsResp spreadsResponse
+	call(t, apiRequest("GET", "/spreads", "", nil), 200, &spreadsResp)
+	if len(spreadsResp.Spreads) != len(spreads) {
+		t.Fatalf("Expected %d spreads, got %d", len(spreads), len(spreadsResp.Spreads))
+	}
+	for i, sp := range spreadsResp.Spreads {
+		if sp.ID != spreads[i].ID || len(sp.Positions) != len(spreads[i].Positions) {
+			t.Errorf("Expected spread %s with %d positions, got %s with %d", spreads[i].ID, len(spreads[i].Positions), sp.ID, len(sp.Positions))
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// deckState is what a deck-state token carries: enough to rebuild the cards
+// left in the deck after a reading, in their shuffled order, and the
+// positions of the reading. Layout holds the positions of a custom spread,
+// which cannot be looked up by Spread like a catalog spread.
+type deckState struct {
+	Deck      string            `json:"d"`
+	Tradition string            `json:"t"`
+	Spread    string            `json:"s,omitempty"`
+	Layout    []*spreadPosition `json:"l,omitempty"`
+	Positions int               `json:"p"`
+	Cards     []deckStateCard   `json:"c"`
+}
+
+// deckStateCard is a card left in the deck: its ID, whether it lies reversed
+// and the copy of the deck it came from, if several were combined
+type deckStateCard struct {
+	ID       string `json:"i"`
+	Reversed bool   `json:"r,omitempty"`
+	Copy     int    `json:"c,omitempty"`
+}
+
+// clarifyRequest is the body of POST /draw/clarify. Position is the index of
+// the reading's position to clarify: the spread position, or the 1-based
+// place of the card in a plain draw.
+type clarifyRequest struct {
+	DeckState       string `json:"deckState"`
+	Position        int    `json:"position"`
+	IncludeMeanings bool   `json:"includeMeanings"`
+	Locale          string `json:"locale"`
+}
+
+type clarifyResponse struct {
+	Position  int    `json:"position"`
+	Card      Card   `json:"card"`
+	Remaining int    `json:"remaining"`
+	DeckState string `json:"deckState"`
+	Locale    string `json:"locale"`
+}
+
+// deckStatePrefix versions the token format
+const deckStatePrefix = "ds2."
+
+var (
+	errInvalidDeckState = errors.New("invalid deck state")
+	errInvalidPosition  = errors.New("position is not in the reading")
+	errDeckExhausted    = errors.New("no cards left in the deck")
+)
+
+// deckStateAEAD seals deck-state tokens with AES-256-GCM under a key derived
+// from the server secret, so a client can neither read the order of the
+// cards left nor change it. Like commitments, tokens only open in containers
+// sharing DRAW_SECRET.
+var deckStateAEAD = newDeckStateAEAD(serverSecret)
+
+func newDeckStateAEAD(secret []byte) cipher.AEAD {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("deck-state-key"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		panic("deck state cipher: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic("deck state cipher: " + err.Error())
+	}
+	return aead
+}
+
+// newDeckState records the cards left after dealt was dealt with opts, so
+// clarifiers can be drawn from them later
+func newDeckState(opts deckOptions, dealt *dealing) *deckState {
+	state := &deckState{
+		Deck:      opts.DeckSize,
+		Tradition: dealt.tradition.Name,
+		Spread:    dealt.spreadID(),
+		Positions: dealt.numCards,
+	}
+	if opts.CustomSpread != nil {
+		state.Layout = dealt.spread.Positions
+	}
+	for _, card := range dealt.remaining {
+		state.Cards = append(state.Cards, deckStateCard{ID: card.ID, Reversed: card.Reversed, Copy: card.Copy})
+	}
+	return state
+}
+
+// seal encrypts the state into an opaque token
+func (s *deckState) seal() (string, error) {
+	plain, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, deckStateAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := deckStateAEAD.Seal(nonce, nonce, plain, nil)
+	return deckStatePrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openDeckState decrypts and checks a token made by seal
+func openDeckState(token string) (*deckState, error) {
+	raw, ok := strings.CutPrefix(token, deckStatePrefix)
+	if !ok {
+		return nil, errInvalidDeckState
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || len(sealed) < deckStateAEAD.NonceSize() {
+		return nil, errInvalidDeckState
+	}
+	nonce, ciphertext := sealed[:deckStateAEAD.NonceSize()], sealed[deckStateAEAD.NonceSize():]
+	plain, err := deckStateAEAD.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errInvalidDeckState
+	}
+
+	var state deckState
+	if err := json.Unmarshal(plain, &state); err != nil {
+		return nil, errInvalidDeckState
+	}
+	return &state, nil
+}
+
+// next takes the top card off the state's deck and attaches it to position
+func (s *deckState) next(position int) (Card, error) {
+	if position < 1 || position > s.Positions {
+		return Card{}, errInvalidPosition
+	}
+	if len(s.Cards) == 0 {
+		return Card{}, errDeckExhausted
+	}
+
+	deck, trad, err := resolveDeck(s.Deck, s.Tradition)
+	if err != nil {
+		return Card{}, errInvalidDeckState
+	}
+	top := s.Cards[0]
+	for _, card := range deck.cards(trad) {
+		if card.ID != top.ID {
+			continue
+		}
+		card.Reversed = top.Reversed
+		card.Copy = top.Copy
+		card.Position = s.position(position)
+		s.Cards = s.Cards[1:]
+		return card, nil
+	}
+	return Card{}, errInvalidDeckState
+}
+
+// position returns the reading's position with the given index: from the
+// custom spread's layout or the catalog spread, or a bare index in a plain
+// draw
+func (s *deckState) position(index int) *spreadPosition {
+	layout := s.Layout
+	if layout == nil {
+		if sp, err := lookupSpread(s.Spread); err == nil {
+			layout = sp.Positions
+		}
+	}
+	if index <= len(layout) {
+		return layout[index-1]
+	}
+	return &spreadPosition{Index: index}
+}
+
+func clarifyHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "POST" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_post_only"))
+	}
+
+	var clarifyReq clarifyRequest
+	if err := decodeRequest(req, &clarifyReq); err != nil {
+		return decodeErrorResult(loc, err)
+	}
+	if clarifyReq.Locale != "" {
+		loc = newLocalizer(clarifyReq.Locale)
+	}
+	if clarifyReq.DeckState == "" {
+		return errorResult(http.StatusBadRequest, "missing_parameters", loc.message("missing_deck_state"))
+	}
+
+	state, err := openDeckState(clarifyReq.DeckState)
+	if err != nil {
+		return errorResult(http.StatusBadRequest, "invalid_deck_state", loc.message("invalid_deck_state"))
+	}
+	card, err := state.next(clarifyReq.Position)
+	switch err {
+	case nil:
+	case errInvalidPosition:
+		return errorResult(http.StatusBadRequest, "invalid_position", loc.message("invalid_position"))
+	case errDeckExhausted:
+		return errorResult(http.StatusConflict, "deck_exhausted", loc.message("deck_exhausted"))
+	default:
+		return errorResult(http.StatusBadRequest, "invalid_deck_state", loc.message("invalid_deck_state"))
+	}
+
+	token, err := state.seal()
+	if err != nil {
+		return errorResult(http.StatusInternalServerError, "internal_error", loc.message("deck_state_failed"))
+	}
+
+	cards := []Card{card}
+	cards[0].Image = cloudFrontURL + "/images/" + cards[0].Image
+	loc.localizeCards(cards, state.Tradition)
+	if clarifyReq.IncludeMeanings {
+		attachMeanings(cards)
+	}
+
+	return jsonResponse(http.StatusOK, clarifyResponse{
+		Position:  clarifyReq.Position,
+		Card:      cards[0],
+		Remaining: len(state.Cards),
+		DeckState: token,
+		Locale:    loc.locale,
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestClarify_NextCardInDeck(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	// The clarifier is the card a longer draw with the same seed deals next
+	var reading drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 3, "seed": "clarify"}`, nil), 200, &reading)
+	var longer drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 5, "seed": "clarify"}`, nil), 200, &longer)
+	if reading.DeckState == "" {
+		t.Fatal("Expected a deckState in the draw response")
+	}
+
+	state := reading.DeckState
+	for i, want := range longer.DrawnCards[3:] {
+		var clarified clarifyResponse
+		call(t, apiRequest("POST", "/draw/clarify", `{"deckState": "`+state+`", "position": 2}`, nil), 200, &clarified)
+		if clarified.Card.ID != want.ID || clarified.Card.Reversed != want.Reversed {
+			t.Errorf("Expected clarifier %d to be %s, got %s", i+1, want.ID, clarified.Card.ID)
+		}
+		if clarified.Position != 2 || clarified.Card.Position.Index != 2 || clarified.Remaining != 74-i {
+			t.Errorf("Expected a card for position 2 with %d left, got %+v", 74-i, clarified)
+		}
+		state = clarified.DeckState
+	}
+}
+
+func TestClarify_SpreadPosition(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var reading drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "spread": "celtic-cross"}`, nil), 200, &reading)
+	var clarified clarifyResponse
+	call(t, apiRequest("POST", "/draw/clarify", `{"deckState": "`+reading.DeckState+`", "position": 2, "locale": "fr"}`, nil), 200, &clarified)
+
+	for _, card := range reading.DrawnCards {
+		if card.ID == clarified.Card.ID {
+			t.Errorf("Expected a card not already in the reading, got %s", card.ID)
+		}
+	}
+	if clarified.Card.Position.Name != "Challenge" || clarified.Locale != "fr" {
+		t.Errorf("Expected a French clarifier for the Challenge, got %+v", clarified)
+	}
+	if !strings.HasPrefix(clarified.Card.Image, "https://test.cloudfront.net/images/") {
+		t.Errorf("Expected a CloudFront image URL, got '%s'", clarified.Card.Image)
+	}
+}
+
+func TestClarify_KeepsCopyAndCustomPositions(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	// A clarifier from combined decks says which copy it came from
+	var reading drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "copies": 2, "numCards": 3, "seed": "copies"}`, nil), 200, &reading)
+	var longer drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "copies": 2, "numCards": 8, "seed": "copies"}`, nil), 200, &longer)
+	state := reading.DeckState
+	for i, want := range longer.DrawnCards[3:] {
+		var clarified clarifyResponse
+		call(t, apiRequest("POST", "/draw/clarify", `{"deckState": "`+state+`", "position": 1}`, nil), 200, &clarified)
+		if got := clarified.Card; got.ID != want.ID || got.Copy != want.Copy || got.Copy == 0 || got.Reversed != want.Reversed {
+			t.Errorf("Expected clarifier %d to be %s from copy %d, got %s from copy %d", i+1, want.ID, want.Copy, got.ID, got.Copy)
+		}
+		state = clarified.DeckState
+	}
+
+	// A clarifier for a custom spread takes its position from the layout sent
+	custom := `{"name": "Crossroads", "positions": [{"name": "Path", "description": "Where you stand", "x": 1, "y": 0}, {"name": "Turn", "x": 1, "y": 0, "rotation": 90}]}`
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "customSpread": `+custom+`}`, nil), 200, &reading)
+	var clarified clarifyResponse
+	call(t, apiRequest("POST", "/draw/clarify", `{"deckState": "`+reading.DeckState+`", "position": 2}`, nil), 200, &clarified)
+	want := reading.DrawnCards[1].Position
+	if got := clarified.Card.Position; got == nil || *got != *want || got.Name != "Turn" || got.Rotation != 90 {
+		t.Errorf("Expected the custom position %+v, got %+v", want, got)
+	}
+}
+
+func TestClarify_Errors(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var reading drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "numCards": 21}`, nil), 200, &reading)
+	var last clarifyResponse
+	call(t, apiRequest("POST", "/draw/clarify", `{"deckState": "`+reading.DeckState+`", "position": 21}`, nil), 200, &last)
+	if last.Remaining != 0 {
+		t.Fatalf("Expected the last card, got %d left", last.Remaining)
+	}
+
+	tampered := []byte(reading.DeckState)
+	tampered[len(tampered)-5] ^= 1
+
+	cases := []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{"position": 1}`, 400, "missing_parameters"},
+		{`{"deckState": "` + string(tampered) + `", "position": 1}`, 400, "invalid_deck_state"},
+		{`{"deckState": "ds2.bm90IGEgdG9rZW4", "position": 1}`, 400, "invalid_deck_state"},
+		{`{"deckState": "` + reading.DeckState + `", "position": 22}`, 400, "invalid_position"},
+		{`{"deckState": "` + reading.DeckState + `", "position": 0}`, 400, "invalid_position"},
+		{`{"deckState": "` + last.DeckState + `", "position": 1}`, 409, "deck_exhausted"},
+	}
+	for _, c := range cases {
+		resp, err := handleRequest(apiRequest("POST", "/draw/clarify", c.body, nil))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var errorResp errorResponse
+		if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+			t.Fatalf("Failed to parse error response: %v", err)
+		}
+		if resp.StatusCode != c.status || errorResp.Error != c.code {
+			t.Errorf("Expected %d %s, got %d %s", c.status, c.code, resp.StatusCode, errorResp.Error)
+		}
+	}
+}
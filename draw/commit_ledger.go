@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// commitLedger records the commitments a draw has used. Once a draw reveals
+// the server seed behind a commitId, another draw with the same commitId and
+// a different clientSeed could be tried until it dealt a chosen reading, so
+// each commitment can only be drawn with once.
+type commitLedger interface {
+	// Use marks commitID as used until expires, or returns errCommitUsed if
+	// it already is
+	Use(commitID string, expires time.Time) error
+}
+
+// commitTTL is how long a commitment can be drawn with after it is issued.
+// The ledger only has to remember a used commitId until then.
+const commitTTL = time.Hour
+
+var errCommitUsed = errors.New("commitment already used")
+
+// usedCommits follows SESSION_STORE: kept in memory by default, so only the
+// container that made a draw knows its commitment is used, or as files in
+// SESSION_DIR that every container sharing the directory sees
+var usedCommits = loadCommitLedger()
+
+func loadCommitLedger() commitLedger {
+	if os.Getenv("SESSION_STORE") != "file" {
+		return newMemoryLedger()
+	}
+	dir := os.Getenv("SESSION_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "tarot-sessions")
+	}
+	ledger, err := newFileLedger(filepath.Join(dir, "commits"))
+	if err != nil {
+		log.Printf("opening the commit ledger: %v, keeping used commitments in memory", err)
+		return newMemoryLedger()
+	}
+	return ledger
+}
+
+// memoryLedger keeps used commitments in the memory of one container
+type memoryLedger struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func newMemoryLedger() *memoryLedger {
+	return &memoryLedger{used: map[string]time.Time{}}
+}
+
+func (m *memoryLedger) Use(commitID string, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Forget commitments that have expired, as they can no longer be drawn with
+	now := time.Now()
+	for id, until := range m.used {
+		if now.After(until) {
+			delete(m.used, id)
+		}
+	}
+	if _, ok := m.used[commitID]; ok {
+		return errCommitUsed
+	}
+	m.used[commitID] = expires
+	return nil
+}
+
+// fileLedger marks a commitment used by creating a file named after it.
+// Creating the file fails if it exists, so two containers sharing the
+// directory cannot both use the same commitment.
+type fileLedger struct {
+	dir string
+}
+
+func newFileLedger(dir string) (*fileLedger, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fileLedger{dir: dir}, nil
+}
+
+func (f *fileLedger) Use(commitID string, expires time.Time) error {
+	file, err := os.OpenFile(filepath.Join(f.dir, commitID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return errCommitUsed
+	}
+	if err != nil {
+		return err
+	}
+	file.Close()
+
+	// The file's modification time records when it can be removed
+	if err := os.Chtimes(file.Name(), expires, expires); err != nil {
+		return err
+	}
+	f.sweep(time.Now())
+	return nil
+}
+
+// sweep removes the records of commitments that have expired
+func (f *fileLedger) sweep(now time.Time) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && now.After(info.ModTime()) {
+			os.Remove(filepath.Join(f.dir, entry.Name()))
+		}
+	}
+}
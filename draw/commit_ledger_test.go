@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testLedger runs the same checks against any commitLedger
+func testLedger(t *testing.T, ledger commitLedger) {
+	t.Helper()
+
+	id := newCommitID(time.Now())
+	expires := time.Now().Add(commitTTL)
+	if err := ledger.Use(id, expires); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := ledger.Use(id, expires); err != errCommitUsed {
+		t.Errorf("Expected errCommitUsed, got %v", err)
+	}
+	if err := ledger.Use(newCommitID(time.Now()), expires); err != nil {
+		t.Errorf("Expected another commitment to be usable, got %v", err)
+	}
+}
+
+func TestMemoryLedger(t *testing.T) {
+	ledger := newMemoryLedger()
+	testLedger(t, ledger)
+
+	// Expired commitments are forgotten by the next use
+	ledger.Use(newCommitID(time.Now()), time.Now().Add(-time.Second))
+	ledger.Use(newCommitID(time.Now()), time.Now().Add(commitTTL))
+	if len(ledger.used) != 3 {
+		t.Errorf("Expected 3 remembered commitments, got %d", len(ledger.used))
+	}
+}
+
+func TestFileLedger(t *testing.T) {
+	dir := t.TempDir()
+	ledger, err := newFileLedger(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	testLedger(t, ledger)
+
+	stale := newCommitID(time.Now().Add(-2 * commitTTL))
+	ledger.Use(stale, time.Now().Add(-commitTTL))
+	ledger.Use(newCommitID(time.Now()), time.Now().Add(commitTTL))
+	if _, err := os.Stat(filepath.Join(dir, stale)); !os.IsNotExist(err) {
+		t.Errorf("Expected the expired record to be removed, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("Expected 3 records, got %d", len(entries))
+	}
+}
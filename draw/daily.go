@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+	// Embed the time zone database, which the Lambda runtime does not ship
+	_ "time/tzdata"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// dailyResponse is the card of the day for one user
+type dailyResponse struct {
+	Date      string `json:"date"`
+	TimeZone  string `json:"timeZone"`
+	Card      Card   `json:"card"`
+	Tradition string `json:"tradition"`
+	Locale    string `json:"locale"`
+}
+
+// maxDailyUserLength bounds the user ID, which is only hashed
+const maxDailyUserLength = 256
+
+// dailySeed derives the seed for user's card on date (YYYY-MM-DD) from the
+// server secret, so the card is fixed for the day but cannot be worked out
+// in advance without the secret
+func dailySeed(user, date string) string {
+	mac := hmac.New(sha256.New, serverSecret)
+	mac.Write([]byte("daily:" + date + ":" + user))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// dailyCard deals the card of the day for user on date. opts chooses the deck
+// and reversals as for a draw; only one card is dealt.
+func dailyCard(user, date string, opts deckOptions) (*dealing, error) {
+	opts.NumCards = 1
+	return dealCards(opts, newSeededSource(dailySeed(user, date)))
+}
+
+// dailyHandler serves GET /daily?user=<id>&tz=<zone>. The day is the
+// calendar day in tz, UTC by default. deckSize (default "Full Deck"),
+// deckReverse (default "Upright and reversed"), tradition, locale and
+// includeMeanings may also be given as query parameters.
+func dailyHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "GET" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_get_only"))
+	}
+
+	query := req.QueryStringParameters
+	if locale := query["locale"]; locale != "" {
+		loc = newLocalizer(locale)
+	}
+
+	user := query["user"]
+	if user == "" || len(user) > maxDailyUserLength {
+		return errorResult(http.StatusBadRequest, "missing_parameters", loc.message("daily_user_required"))
+	}
+
+	tz := query["tz"]
+	if tz == "" {
+		tz = "UTC"
+	}
+	zone, err := time.LoadLocation(tz)
+	if err != nil {
+		return errorResult(http.StatusBadRequest, "invalid_timezone", loc.message("invalid_timezone"))
+	}
+	date := time.Now().In(zone).Format(time.DateOnly)
+
+	opts := deckOptions{
+		DeckSize:    query["deckSize"],
+		DeckReverse: query["deckReverse"],
+		Tradition:   query["tradition"],
+	}
+	if opts.DeckSize == "" {
+		opts.DeckSize = "Full Deck"
+	}
+	if opts.DeckReverse == "" {
+		opts.DeckReverse = "Upright and reversed"
+	}
+
+	dealt, err := dailyCard(user, date, opts)
+	if err != nil {
+		return dealErrorResult(loc, err)
+	}
+
+	cards := dealt.cards
+	cards[0].Image = cloudFrontURL + "/images/" + cards[0].Image
+	loc.localizeCards(cards, dealt.tradition.Name)
+	if query["includeMeanings"] == "true" {
+		attachMeanings(cards)
+	}
+
+	return jsonResponse(http.StatusOK, dailyResponse{
+		Date:      date,
+		TimeZone:  zone.String(),
+		Card:      cards[0],
+		Tradition: dealt.tradition.Name,
+		Locale:    loc.locale,
+	})
+}
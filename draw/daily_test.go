@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDailyCard_FixedPerUserAndDay(t *testing.T) {
+	opts := deckOptions{DeckSize: "Full Deck", DeckReverse: "Upright and reversed"}
+	card := func(user, date string) Card {
+		t.Helper()
+		dealt, err := dailyCard(user, date, opts)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(dealt.cards) != 1 {
+			t.Fatalf("Expected one card, got %d", len(dealt.cards))
+		}
+		return dealt.cards[0]
+	}
+
+	first := card("alice", "2026-10-18")
+	if again := card("alice", "2026-10-18"); again.ID != first.ID || again.Reversed != first.Reversed {
+		t.Errorf("Expected the same card on the same day, got %s and %s", first.ID, again.ID)
+	}
+
+	// Over a month the card changes from day to day and differs between users
+	days, users := map[string]bool{}, 0
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		date := day.AddDate(0, 0, i).Format(time.DateOnly)
+		days[card("alice", date).ID] = true
+		if card("alice", date).ID != card("bob", date).ID {
+			users++
+		}
+	}
+	if len(days) < 20 {
+		t.Errorf("Expected the card to change from day to day, got %d distinct cards in 30 days", len(days))
+	}
+	if users < 20 {
+		t.Errorf("Expected users to get different cards, got %d different days out of 30", users)
+	}
+}
+
+func TestDailyHandler(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	resp, err := handleRequest(apiRequest("GET", "/daily", "", map[string]string{"user": "alice", "tz": "Pacific/Kiritimati", "deckSize": "Major Arcana only"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var dailyResp dailyResponse
+	if err := json.Unmarshal([]byte(resp.Body), &dailyResp); err != nil {
+		t.Fatalf("Failed to parse daily response: %v", err)
+	}
+	zone, _ := time.LoadLocation("Pacific/Kiritimati")
+	if today := time.Now().In(zone).Format(time.DateOnly); dailyResp.Date != today || dailyResp.TimeZone != "Pacific/Kiritimati" {
+		t.Errorf("Expected %s in Pacific/Kiritimati, got %s in %s", today, dailyResp.Date, dailyResp.TimeZone)
+	}
+	if dailyResp.Card.Arcana != ArcanaMajor {
+		t.Errorf("Expected a major arcana card, got %s", dailyResp.Card.ID)
+	}
+
+	cases := []struct {
+		query map[string]string
+		code  string
+	}{
+		{map[string]string{}, "missing_parameters"},
+		{map[string]string{"user": "alice", "tz": "Mars/Olympus_Mons"}, "invalid_timezone"},
+		{map[string]string{"user": "alice", "deckSize": "Tiny Deck"}, "invalid_deck_options"},
+	}
+	for _, c := range cases {
+		resp, err := handleRequest(apiRequest("GET", "/daily", "", c.query))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var errorResp errorResponse
+		if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+			t.Fatalf("Failed to parse error response: %v", err)
+		}
+		if resp.StatusCode != 400 || errorResp.Error != c.code {
+			t.Errorf("%v: expected 400 %s, got %d %s", c.query, c.code, resp.StatusCode, errorResp.Error)
+		}
+	}
+}
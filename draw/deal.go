@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// deckOptions are the request fields that decide which cards are dealt. They
+// are shared by POST /draw and POST /draw/verify so that a verified draw is
+// dealt exactly as the original was.
+type deckOptions struct {
+	DeckSize     string          `json:"deckSize"`
+	DeckReverse  string          `json:"deckReverse"`
+	NumCards     int             `json:"numCards"`
+	Tradition    string          `json:"tradition,omitempty"`
+	Spread       string          `json:"spread,omitempty"`
+	CustomSpread *spread         `json:"customSpread,omitempty"`
+	Significator *significator   `json:"significator,omitempty"`
+	Filter       *deckFilter     `json:"filter,omitempty"`
+	Reversal     *reversal       `json:"reversal,omitempty"`
+	Shuffle      *shuffleOptions `json:"shuffle,omitempty"`
+	Copies       int             `json:"copies,omitempty"`
+	Replacement  bool            `json:"replacement,omitempty"`
+}
+
+// significator picks the card that stands for the querent, either by card ID
+// or by the rank name of a suit in the chosen tradition, e.g.
+// {"suit": "cups", "court": "queen"}. A bare string is taken as a card ID.
+type significator struct {
+	ID    string `json:"id,omitempty"`
+	Suit  Suit   `json:"suit,omitempty"`
+	Court string `json:"court,omitempty"`
+}
+
+// significatorPosition is where the significator is placed, ahead of the
+// cards dealt from the rest of the deck
+var significatorPosition = &spreadPosition{
+	Index:       0,
+	Name:        "Significator",
+	Description: "The card that stands for the querent.",
+}
+
+const (
+	// maxDeckCopies bounds how many copies of a deck can be combined
+	maxDeckCopies = 8
+	// maxReplacementCards bounds a draw with replacement, which has no deck
+	// size to stop it
+	maxReplacementCards = 500
+)
+
+var (
+	errUnknownSignificator = errors.New("significator is not in the deck")
+	errInvalidDrawMode     = errors.New("invalid copies or replacement draw")
+)
+
+func (s *significator) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*s = significator{ID: id}
+		return nil
+	}
+	type plain significator
+	*s = significator{}
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// find returns the index of the significator in deck
+func (s *significator) find(deck []Card) (int, error) {
+	var match func(Card) bool
+	switch {
+	case s.ID != "" && s.Suit == "" && s.Court == "":
+		match = func(card Card) bool { return card.ID == s.ID }
+	case s.ID == "" && s.Suit != "" && s.Court != "":
+		match = func(card Card) bool { return card.Suit == s.Suit && strings.EqualFold(card.Number, s.Court) }
+	default:
+		return -1, errUnknownSignificator
+	}
+
+	if i := slices.IndexFunc(deck, match); i >= 0 {
+		return i, nil
+	}
+	return -1, errUnknownSignificator
+}
+
+// dealing is the outcome of dealing a reading from a seeded deck
+type dealing struct {
+	cards     []Card
+	tradition *tradition
+	spread    *spread
+	// numCards counts the cards dealt from the deck, leaving out any significator
+	numCards int
+	// clamped is set when numCards asked for more cards than the deck holds
+	clamped bool
+	// shuffle records the steps of a requested shuffle strategy
+	shuffle []shuffleStep
+	// remaining holds the cards left in the deck, in their shuffled order
+	remaining []Card
+}
+
+// shuffledDeck builds the requested deck, combining Copies copies of it,
+// takes out any significator and shuffles the rest with src, by
+// Fisher-Yates or the requested strategy. It
+// returns the shuffled deck and a dealing holding the significator, if any,
+// upright and at position 0, ready for cards to be dealt after it.
+func shuffledDeck(opts deckOptions, src RandomSource) ([]Card, *dealing, error) {
+	layout, err := resolveSpread(opts.Spread, opts.CustomSpread)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Shuffle != nil {
+		if err := opts.Shuffle.validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if opts.Copies < 0 || opts.Copies > maxDeckCopies {
+		return nil, nil, errInvalidDrawMode
+	}
+
+	// Each copy is built on its own, so its reversals are drawn independently
+	var deck []Card
+	var trad *tradition
+	for c := 1; c <= max(opts.Copies, 1); c++ {
+		cards, t, err := getDeck(opts.DeckSize, opts.DeckReverse, opts.Tradition, opts.Filter, opts.Reversal, src)
+		if err != nil {
+			return nil, nil, err
+		}
+		if opts.Copies > 1 {
+			for i := range cards {
+				cards[i].Copy = c
+			}
+		}
+		deck, trad = append(deck, cards...), t
+	}
+
+	d := &dealing{tradition: trad, spread: layout}
+	if opts.Significator != nil {
+		i, err := opts.Significator.find(deck)
+		if err != nil {
+			return nil, nil, err
+		}
+		card := deck[i]
+		card.Reversed = false
+		card.Position = significatorPosition
+		d.cards = []Card{card}
+		deck = slices.Delete(deck, i, i+1)
+	}
+
+	if opts.Shuffle != nil {
+		deck, d.shuffle = opts.Shuffle.apply(deck, src)
+	} else {
+		deck = shuffle(deck, src)
+	}
+	return deck, d, nil
+}
+
+// dealCards shuffles the requested deck with src and deals either the
+// spread's positions or numCards cards after any significator. With
+// Replacement each card is picked at random and returned before the next is
+// dealt, so cards can repeat and numCards can exceed the deck.
+func dealCards(opts deckOptions, src RandomSource) (*dealing, error) {
+	if opts.Replacement {
+		return dealWithReplacement(opts, src)
+	}
+
+	deck, d, err := shuffledDeck(opts, src)
+	if err != nil {
+		return nil, err
+	}
+
+	// A filtered deck must hold every card asked for
+	requested := opts.requested(d.spread)
+	if opts.Filter != nil && requested > len(deck) {
+		return nil, &tooFewCardsError{remaining: len(deck), requested: requested}
+	}
+
+	var dealt []Card
+	if d.spread != nil {
+		if dealt, d.remaining, err = d.spread.deal(deck); err != nil {
+			return nil, err
+		}
+		d.numCards = len(dealt)
+	} else {
+		d.numCards = requested
+		if d.numCards > len(deck) {
+			d.numCards = len(deck)
+			d.clamped = true
+		}
+		dealt, d.remaining = deck[:d.numCards], deck[d.numCards:]
+	}
+
+	d.cards = append(d.cards, dealt...)
+	return d, nil
+}
+
+// requested returns the number of cards to deal: one for each position of
+// layout, or numCards, 8 by default
+func (opts deckOptions) requested(layout *spread) int {
+	switch {
+	case layout != nil:
+		return len(layout.Positions)
+	case opts.NumCards < 1:
+		return 8
+	default:
+		return opts.NumCards
+	}
+}
+
+// dealWithReplacement builds and shuffles the deck once, then deals each
+// card by picking one of its cards at random and putting it back, so every
+// card can be dealt any number of times and keeps the orientation it was
+// given when the deck was built. No cards remain for clarifiers.
+func dealWithReplacement(opts deckOptions, src RandomSource) (*dealing, error) {
+	deck, d, err := shuffledDeck(opts, src)
+	if err != nil {
+		return nil, err
+	}
+	requested := opts.requested(d.spread)
+	if requested > maxReplacementCards {
+		return nil, errInvalidDrawMode
+	}
+	if len(deck) == 0 {
+		return nil, &tooFewCardsError{remaining: 0, requested: requested}
+	}
+
+	for i := 0; i < requested; i++ {
+		if d.spread == nil {
+			d.cards = append(d.cards, deck[uniformInt(src, len(deck))])
+			continue
+		}
+		// Pick only among the cards that meet the position's rule
+		pos := d.spread.Positions[i]
+		eligible := deck
+		if pos.Rule != nil {
+			eligible = slices.DeleteFunc(slices.Clone(deck), func(card Card) bool { return !pos.Rule.accepts(card) })
+			if len(eligible) == 0 {
+				return nil, errSpreadTooLarge
+			}
+		}
+		card := eligible[uniformInt(src, len(eligible))]
+		card.Position = pos
+		d.cards = append(d.cards, card)
+	}
+	d.numCards = requested
+	return d, nil
+}
+
+// spreadID returns the ID of the spread dealt, or "" without one
+func (d *dealing) spreadID() string {
+	if d.spread == nil {
+		return ""
+	}
+	return d.spread.ID
+}
+
+// dealErrorResult builds the error response for a dealCards error
+func dealErrorResult(loc localizer, err error) (events.APIGatewayV2HTTPResponse, error) {
+	var tooFew *tooFewCardsError
+	if errors.As(err, &tooFew) {
+		return errorResult(http.StatusBadRequest, "not_enough_cards", fmt.Sprintf(loc.message("not_enough_cards"), tooFew.remaining, tooFew.requested))
+	}
+
+	switch err {
+	case errUnknownDeck:
+		return errorResult(http.StatusBadRequest, "invalid_deck_options", loc.message("invalid_deck_options"))
+	case errUnknownTradition:
+		return errorResult(http.StatusBadRequest, "invalid_tradition", loc.message("invalid_tradition"))
+	case errInvalidFilter:
+		return errorResult(http.StatusBadRequest, "invalid_filter", loc.message("invalid_filter"))
+	case errInvalidShuffle:
+		return errorResult(http.StatusBadRequest, "invalid_shuffle", loc.message("invalid_shuffle"))
+	case errInvalidDrawMode:
+		return errorResult(http.StatusBadRequest, "invalid_draw_mode", loc.message("invalid_draw_mode"))
+	case errInvalidReversal:
+		return errorResult(http.StatusBadRequest, "invalid_reversal", loc.message("invalid_reversal"))
+	case errUnknownSignificator:
+		return errorResult(http.StatusBadRequest, "invalid_significator", loc.message("invalid_significator"))
+	default:
+		return spreadErrorResult(loc, err)
+	}
+}
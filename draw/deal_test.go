@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSignificator_UnmarshalJSON(t *testing.T) {
+	var opts deckOptions
+	if err := json.Unmarshal([]byte(`{"significator": "cups-13"}`), &opts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if opts.Significator == nil || opts.Significator.ID != "cups-13" {
+		t.Errorf("Expected significator by ID, got %+v", opts.Significator)
+	}
+
+	if err := json.Unmarshal([]byte(`{"significator": {"suit": "wands", "court": "King"}}`), &opts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if *opts.Significator != (significator{Suit: SuitWands, Court: "King"}) {
+		t.Errorf("Expected significator by rule, got %+v", opts.Significator)
+	}
+}
+
+func TestSignificator_Find(t *testing.T) {
+	rws, _, _ := getDeck("Full Deck", "Upright only", "", nil, nil, defaultSource)
+	thoth, _, _ := getDeck("Full Deck", "Upright only", "thoth", nil, nil, defaultSource)
+
+	cases := []struct {
+		sig  significator
+		deck []Card
+		want string
+	}{
+		{significator{ID: "major-09-hermit"}, rws, "major-09-hermit"},
+		{significator{Suit: SuitCups, Court: "queen"}, rws, "cups-13"},
+		{significator{Suit: SuitSwords, Court: "Page"}, rws, "swords-11"},
+		// Courts are named by the tradition: the Thoth Knight is rank 14
+		{significator{Suit: SuitWands, Court: "knight"}, thoth, "wands-14"},
+	}
+	for _, c := range cases {
+		i, err := c.sig.find(c.deck)
+		if err != nil {
+			t.Errorf("%+v: expected %s, got %v", c.sig, c.want, err)
+			continue
+		}
+		if c.deck[i].ID != c.want {
+			t.Errorf("%+v: expected %s, got %s", c.sig, c.want, c.deck[i].ID)
+		}
+	}
+
+	invalid := []significator{
+		{},
+		{ID: "hearts-01"},
+		{Suit: SuitCups},
+		{Court: "queen"},
+		{ID: "cups-13", Suit: SuitCups, Court: "queen"},
+		{Suit: SuitCups, Court: "princess"},
+	}
+	for _, sig := range invalid {
+		if _, err := sig.find(rws); err != errUnknownSignificator {
+			t.Errorf("%+v: expected errUnknownSignificator, got %v", sig, err)
+		}
+	}
+}
+
+func TestDealCards_Significator(t *testing.T) {
+	opts := deckOptions{
+		DeckSize:     "Full Deck",
+		DeckReverse:  "Upright and reversed",
+		NumCards:     77,
+		Significator: &significator{Suit: SuitCups, Court: "queen"},
+	}
+
+	dealt, err := dealCards(opts, newSeededSource("significator"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(dealt.cards) != 78 || dealt.numCards != 77 || dealt.clamped {
+		t.Fatalf("Expected the significator and 77 cards, got %d cards (numCards %d)", len(dealt.cards), dealt.numCards)
+	}
+
+	sig := dealt.cards[0]
+	if sig.ID != "cups-13" || sig.Reversed || sig.Position == nil || sig.Position.Index != 0 {
+		t.Errorf("Expected the upright Queen of Cups at position 0, got %+v", sig)
+	}
+	for _, card := range dealt.cards[1:] {
+		if card.ID == "cups-13" {
+			t.Error("Expected the significator to be taken out of the deck")
+		}
+	}
+
+	// With a spread, the significator precedes position 1
+	opts.Spread = "three-card"
+	dealt, err = dealCards(opts, newSeededSource("significator"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i, card := range dealt.cards {
+		if card.Position.Index != i {
+			t.Errorf("Expected position %d, got %d", i, card.Position.Index)
+		}
+	}
+}
+
+func TestDrawHandler_Significator(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 3, "significator": "major-09-hermit", "locale": "fr"}`, nil), 200, &drawResp)
+	if len(drawResp.DrawnCards) != 4 {
+		t.Fatalf("Expected the significator and 3 cards, got %d", len(drawResp.DrawnCards))
+	}
+	if sig := drawResp.DrawnCards[0]; sig.ID != "major-09-hermit" || sig.NameSuit != catalogs["fr"].Traditions["rws"].Cards[sig.ID] || sig.Position.Name != "Significator" {
+		t.Errorf("Expected the localized Hermit as significator, got %+v", sig)
+	}
+
+	resp, err := drawHandler(apiRequest("POST", "/draw", `{"deckSize": "Minor Arcana only", "deckReverse": "Upright only", "significator": "major-09-hermit"}`, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var errorResp errorResponse
+	if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+		t.Fatalf("Failed to parse error response: %v", err)
+	}
+	if resp.StatusCode != 400 || errorResp.Error != "invalid_significator" {
+		t.Errorf("Expected 400 invalid_significator, got %d %s", resp.StatusCode, errorResp.Error)
+	}
+}
+
+func TestDealCards_Copies(t *testing.T) {
+	opts := deckOptions{DeckSize: "Major Arcana only", DeckReverse: "Upright and reversed", NumCards: 100, Copies: 3}
+
+	dealt, err := dealCards(opts, newSeededSource("copies"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(dealt.cards) != 66 || !dealt.clamped {
+		t.Fatalf("Expected every card of three decks, got %d", len(dealt.cards))
+	}
+
+	seen := map[string]map[int]bool{}
+	for _, card := range dealt.cards {
+		if card.Copy < 1 || card.Copy > 3 {
+			t.Errorf("Expected a copy from 1 to 3, got %d", card.Copy)
+		}
+		if seen[card.ID] == nil {
+			seen[card.ID] = map[int]bool{}
+		}
+		if seen[card.ID][card.Copy] {
+			t.Errorf("Expected copy %d of %s once", card.Copy, card.ID)
+		}
+		seen[card.ID][card.Copy] = true
+	}
+	if len(seen) != 22 {
+		t.Errorf("Expected 22 distinct cards, got %d", len(seen))
+	}
+
+	for _, copies := range []int{-1, maxDeckCopies + 1} {
+		opts.Copies = copies
+		if _, err := dealCards(opts, defaultSource); err != errInvalidDrawMode {
+			t.Errorf("Copies %d: expected errInvalidDrawMode, got %v", copies, err)
+		}
+	}
+}
+
+func TestDealCards_Replacement(t *testing.T) {
+	opts := deckOptions{DeckSize: "Major Arcana only", DeckReverse: "Upright only", NumCards: 200, Replacement: true}
+
+	dealt, err := dealCards(opts, newSeededSource("replacement"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(dealt.cards) != 200 || dealt.clamped || dealt.remaining != nil {
+		t.Fatalf("Expected 200 cards and none left over, got %d", len(dealt.cards))
+	}
+	counts := map[string]int{}
+	for _, card := range dealt.cards {
+		counts[card.ID]++
+	}
+	if len(counts) != 22 {
+		t.Errorf("Expected every major to turn up in 200 draws, got %d", len(counts))
+	}
+
+	// Each position of a spread still follows its rule
+	opts.DeckSize, opts.CustomSpread = "Full Deck", &spread{Positions: []*spreadPosition{
+		{Name: "Cups", Rule: &spreadRule{Suits: []Suit{SuitCups}}},
+		{Name: "Cups again", Rule: &spreadRule{Suits: []Suit{SuitCups}}},
+	}}
+	dealt, err = dealCards(opts, newSeededSource("replacement"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, card := range dealt.cards {
+		if card.Suit != SuitCups {
+			t.Errorf("Expected cups for %s, got %s", card.Position.Name, card.ID)
+		}
+	}
+
+	// The deck is shuffled once, so the steps recorded are the shuffle of the
+	// deck the cards were picked from, as a draw without replacement records
+	opts.CustomSpread, opts.NumCards, opts.Copies = nil, maxReplacementCards, maxDeckCopies
+	opts.Shuffle = &shuffleOptions{Strategy: "riffle", Passes: 7, Cut: true}
+	dealt, err = dealCards(opts, newSeededSource("replacement"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	without := opts
+	without.Replacement, without.NumCards = false, 1
+	once, _ := dealCards(without, newSeededSource("replacement"))
+	if len(dealt.shuffle) != 9 || !reflect.DeepEqual(dealt.shuffle, once.shuffle) {
+		t.Errorf("Expected the 9 steps of one riffle shuffle, got %d", len(dealt.shuffle))
+	}
+
+	opts.Shuffle, opts.Copies, opts.NumCards = nil, 0, maxReplacementCards+1
+	if _, err := dealCards(opts, defaultSource); err != errInvalidDrawMode {
+		t.Errorf("Expected errInvalidDrawMode, got %v", err)
+	}
+}
+
+func TestDrawHandler_Replacement(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 100, "replacement": true}`, nil), 200, &drawResp)
+	if len(drawResp.DrawnCards) != 100 || drawResp.Message != "" || drawResp.DeckState != "" {
+		t.Errorf("Expected 100 cards without a message or deck state, got %d, '%s'", len(drawResp.DrawnCards), drawResp.Message)
+	}
+
+	drawResp = drawResponse{}
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 156, "copies": 2}`, nil), 200, &drawResp)
+	if len(drawResp.DrawnCards) != 156 || drawResp.DrawnCards[0].Copy == 0 {
+		t.Errorf("Expected 156 numbered copies, got %d", len(drawResp.DrawnCards))
+	}
+
+	resp, err := drawHandler(apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "copies": 20}`, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var errorResp errorResponse
+	if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+		t.Fatalf("Failed to parse error response: %v", err)
+	}
+	if resp.StatusCode != 400 || errorResp.Error != "invalid_draw_mode" {
+		t.Errorf("Expected 400 invalid_draw_mode, got %d %s", resp.StatusCode, errorResp.Error)
+	}
+}
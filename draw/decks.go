@@ -0,0 +1,312 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// cardSet is one deck definition file: a set of cards, the traditions that
+// name them and the decks that can be drawn from them. See
+// data/decks/deck.schema.json for the file format.
+type cardSet struct {
+	ID               string                `json:"id"`
+	Name             string                `json:"name"`
+	Numerals         []string              `json:"numerals"`
+	CourtRanks       []int                 `json:"courtRanks"`
+	DefaultTradition string                `json:"defaultTradition"`
+	Traditions       map[string]*tradition `json:"traditions"`
+	Decks            []*deckDefinition     `json:"decks"`
+	Cards            []cardDefinition      `json:"cards"`
+}
+
+// cardDefinition is a card as it appears in every tradition of its set.
+// Cards without a suit are named by their tradition's title and ordered by
+// number; suited cards are named by rank and suit and keep file order.
+type cardDefinition struct {
+	ID     string `json:"id"`
+	Arcana Arcana `json:"arcana,omitempty"`
+	Suit   Suit   `json:"suit,omitempty"`
+	Rank   int    `json:"rank"`
+	Image  string `json:"image"`
+}
+
+// deckDefinition is a deck that can be requested by ID or by one of its
+// aliases, such as the "Full Deck" deckSize the frontend sends
+type deckDefinition struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	// Arcana limits the deck to cards of these arcana; empty means every card
+	Arcana []Arcana `json:"arcana,omitempty"`
+
+	set *cardSet
+}
+
+var (
+	errUnknownDeck      = errors.New("unknown deck")
+	errUnknownTradition = errors.New("unknown tradition")
+)
+
+//go:embed data/decks/*.deck.json
+var deckFiles embed.FS
+
+// cardSets holds every embedded card set, keyed by set ID
+var cardSets = loadCardSets()
+
+// deckIndex holds every deck, keyed by deck ID and by each alias
+var deckIndex = indexDecks(cardSets)
+
+func loadCardSets() map[string]*cardSet {
+	entries, err := deckFiles.ReadDir("data/decks")
+	if err != nil {
+		panic("reading data/decks: " + err.Error())
+	}
+
+	var sets []*cardSet
+	for _, entry := range entries {
+		data, err := deckFiles.ReadFile("data/decks/" + entry.Name())
+		if err != nil {
+			panic("reading data/decks/" + entry.Name() + ": " + err.Error())
+		}
+		var set cardSet
+		if err := json.Unmarshal(data, &set); err != nil {
+			panic("invalid data/decks/" + entry.Name() + ": " + err.Error())
+		}
+		set.link()
+		sets = append(sets, &set)
+	}
+
+	if err := validateCardSets(sets); err != nil {
+		panic("invalid data/decks: " + err.Error())
+	}
+
+	loaded := map[string]*cardSet{}
+	for _, set := range sets {
+		loaded[set.ID] = set
+	}
+	return loaded
+}
+
+// link names each tradition after its key and points each deck at the set
+func (s *cardSet) link() {
+	for name, trad := range s.Traditions {
+		trad.Name = name
+	}
+	for _, deck := range s.Decks {
+		deck.set = s
+	}
+}
+
+// validateCardSets checks that every card has a unique ID and an image, that
+// every tradition names and numbers every card, and that deck IDs and aliases
+// are unique and select at least one card
+func validateCardSets(sets []*cardSet) error {
+	setIDs := map[string]bool{}
+	cardIDs := map[string]bool{}
+	deckNames := map[string]bool{}
+
+	for _, set := range sets {
+		if set.ID == "" {
+			return errors.New("card set without an id")
+		}
+		if setIDs[set.ID] {
+			return fmt.Errorf("duplicate card set %q", set.ID)
+		}
+		setIDs[set.ID] = true
+
+		if len(set.Cards) == 0 {
+			return fmt.Errorf("%s: no cards", set.ID)
+		}
+		for _, def := range set.Cards {
+			if def.ID == "" {
+				return fmt.Errorf("%s: card without an id", set.ID)
+			}
+			if cardIDs[def.ID] {
+				return fmt.Errorf("%s: duplicate card id %q", set.ID, def.ID)
+			}
+			cardIDs[def.ID] = true
+			if def.Image == "" {
+				return fmt.Errorf("%s: card %q has no image", set.ID, def.ID)
+			}
+			if def.Arcana != "" && def.Arcana != ArcanaMajor && def.Arcana != ArcanaMinor {
+				return fmt.Errorf("%s: card %q has unknown arcana %q", set.ID, def.ID, def.Arcana)
+			}
+		}
+
+		if _, ok := set.Traditions[set.DefaultTradition]; !ok {
+			return fmt.Errorf("%s: default tradition %q is not defined", set.ID, set.DefaultTradition)
+		}
+		for name, trad := range set.Traditions {
+			if err := validateTradition(set, trad); err != nil {
+				return fmt.Errorf("%s: tradition %s: %w", set.ID, name, err)
+			}
+		}
+
+		if len(set.Decks) == 0 {
+			return fmt.Errorf("%s: no decks", set.ID)
+		}
+		for _, deck := range set.Decks {
+			for _, name := range append([]string{deck.ID}, deck.Aliases...) {
+				if name == "" {
+					return fmt.Errorf("%s: deck without an id", set.ID)
+				}
+				if deckNames[name] {
+					return fmt.Errorf("%s: duplicate deck id or alias %q", set.ID, name)
+				}
+				deckNames[name] = true
+			}
+			for _, arcana := range deck.Arcana {
+				if arcana != ArcanaMajor && arcana != ArcanaMinor {
+					return fmt.Errorf("%s: deck %q has unknown arcana %q", set.ID, deck.ID, arcana)
+				}
+			}
+			if len(deck.definitions()) == 0 {
+				return fmt.Errorf("%s: deck %q has no cards", set.ID, deck.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func validateTradition(set *cardSet, trad *tradition) error {
+	numbers := map[int]string{}
+	for _, def := range set.Cards {
+		if def.Suit != SuitNone {
+			if trad.rankName(def.Rank) == "" {
+				return fmt.Errorf("no name for rank %d", def.Rank)
+			}
+			if trad.Suits[def.Suit] == "" {
+				return fmt.Errorf("no name for suit %q", def.Suit)
+			}
+			continue
+		}
+		if trad.Titles[def.ID] == "" {
+			return fmt.Errorf("no title for %q", def.ID)
+		}
+		number := trad.number(def)
+		if other, ok := numbers[number]; ok {
+			return fmt.Errorf("%q and %q are both number %d", other, def.ID, number)
+		}
+		numbers[number] = def.ID
+	}
+	for id := range trad.Numbers {
+		if !slices.ContainsFunc(set.Cards, func(def cardDefinition) bool { return def.ID == id }) {
+			return fmt.Errorf("number for unknown card %q", id)
+		}
+	}
+	return nil
+}
+
+func indexDecks(sets map[string]*cardSet) map[string]*deckDefinition {
+	index := map[string]*deckDefinition{}
+	for _, set := range sets {
+		for _, deck := range set.Decks {
+			index[deck.ID] = deck
+			for _, alias := range deck.Aliases {
+				index[alias] = deck
+			}
+		}
+	}
+	return index
+}
+
+// resolveDeck returns the deck with the given ID or alias and the named
+// tradition of its card set, or the set's default tradition when tradName is
+// empty
+func resolveDeck(deckID, tradName string) (*deckDefinition, *tradition, error) {
+	deck, ok := deckIndex[deckID]
+	if !ok {
+		return nil, nil, errUnknownDeck
+	}
+	trad, ok := deck.set.lookupTradition(tradName)
+	if !ok {
+		return nil, nil, errUnknownTradition
+	}
+	return deck, trad, nil
+}
+
+// definitions returns the card definitions the deck includes, in file order
+func (d *deckDefinition) definitions() []cardDefinition {
+	var defs []cardDefinition
+	for _, def := range d.set.Cards {
+		if len(d.Arcana) == 0 || slices.Contains(d.Arcana, def.Arcana) {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// cards builds the deck's cards, named and numbered by trad. Unsuited cards
+// come first in trad's numbering, followed by the suited cards in file order.
+func (d *deckDefinition) cards(trad *tradition) []Card {
+	var unsuited, suited []Card
+	for _, def := range d.definitions() {
+		card := Card{
+			ID:     def.ID,
+			Arcana: def.Arcana,
+			Suit:   def.Suit,
+			Rank:   def.Rank,
+			Image:  def.Image,
+		}
+		if def.Suit == SuitNone {
+			card.Rank = trad.number(def)
+			card.Number = d.set.numeral(card.Rank)
+			card.NameSuit = trad.Titles[def.ID]
+			unsuited = append(unsuited, card)
+			continue
+		}
+		card.Number = trad.rankName(def.Rank)
+		card.NameSuit = "of " + trad.Suits[def.Suit]
+		suited = append(suited, card)
+	}
+
+	sort.SliceStable(unsuited, func(i, j int) bool { return unsuited[i].Rank < unsuited[j].Rank })
+	return append(unsuited, suited...)
+}
+
+// numeral returns the display numeral for an unsuited card's number
+func (s *cardSet) numeral(number int) string {
+	if number >= 0 && number < len(s.Numerals) {
+		return s.Numerals[number]
+	}
+	return strconv.Itoa(number)
+}
+
+// getDeck builds the deck with the given ID or alias, naming its cards after
+// the named tradition, keeping only the cards filter allows (if not nil) and
+// drawing any reversals from src, as rev sets out or with even chances when
+// rev is nil. It also returns the tradition used, which is the card set's
+// default when tradName is empty.
+func getDeck(deckID, deckReverse, tradName string, filter *deckFilter, rev *reversal, src RandomSource) ([]Card, *tradition, error) {
+	deck, trad, err := resolveDeck(deckID, tradName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cards := deck.cards(trad)
+	if filter != nil {
+		if err := filter.validate(deck.set); err != nil {
+			return nil, nil, err
+		}
+		cards = filter.apply(cards, deck.set)
+	}
+	if rev != nil {
+		// A reversal policy only makes sense for a deck drawn with reversals
+		if err := rev.validate(); err != nil || deckReverse != "Upright and reversed" {
+			return nil, nil, errInvalidReversal
+		}
+	}
+	switch {
+	case deckReverse != "Upright and reversed":
+	case rev != nil:
+		cards = rev.apply(cards, src)
+	default:
+		cards = includeReversed(cards, src)
+	}
+	return cards, trad, nil
+}
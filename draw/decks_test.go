@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// tarotDeck builds the named tarot deck in the named tradition
+func tarotDeck(deckID, tradName string) []Card {
+	deck, trad, err := resolveDeck(deckID, tradName)
+	if err != nil {
+		panic(err)
+	}
+	return deck.cards(trad)
+}
+
+// tarotTradition returns the named tradition of the tarot card set
+func tarotTradition(name string) *tradition {
+	return cardSets["tarot"].Traditions[name]
+}
+
+// testCardSet parses a card set and links it, as loadCardSets does
+func testCardSet(t *testing.T, data string) *cardSet {
+	t.Helper()
+
+	var set cardSet
+	if err := json.Unmarshal([]byte(data), &set); err != nil {
+		t.Fatalf("Failed to parse card set: %v", err)
+	}
+	set.link()
+	return &set
+}
+
+const validCardSet = `{
+	"id": "test",
+	"name": "Test",
+	"defaultTradition": "plain",
+	"traditions": {
+		"plain": {
+			"titles": {"fool": "The Fool", "magician": "The Magician"},
+			"ranks": {"1": "Ace", "2": "Two"},
+			"suits": {"cups": "Cups"}
+		}
+	},
+	"decks": [{"id": "test-full", "name": "Full", "aliases": ["Everything"]}],
+	"cards": [
+		{"id": "fool", "arcana": "major", "rank": 0, "image": "fool.jpg"},
+		{"id": "magician", "arcana": "major", "rank": 1, "image": "magician.jpg"},
+		{"id": "cups-1", "arcana": "minor", "suit": "cups", "rank": 1, "image": "cups1.jpg"},
+		{"id": "cups-2", "arcana": "minor", "suit": "cups", "rank": 2, "image": "cups2.jpg"}
+	]
+}`
+
+func TestValidateCardSets_Valid(t *testing.T) {
+	if err := validateCardSets([]*cardSet{testCardSet(t, validCardSet)}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestValidateCardSets_Rejects(t *testing.T) {
+	cases := map[string]struct {
+		old, new string
+	}{
+		"duplicate card id":    {`"id": "magician"`, `"id": "fool"`},
+		"missing image":        {`"image": "cups2.jpg"`, `"image": ""`},
+		"missing title":        {`"magician": "The Magician"`, `"hermit": "The Hermit"`},
+		"missing rank name":    {`"2": "Two"`, `"3": "Three"`},
+		"missing suit name":    {`"suits": {"cups": "Cups"}`, `"suits": {}`},
+		"duplicate number":     {`"ranks"`, `"numbers": {"magician": 0}, "ranks"`},
+		"unknown default":      {`"defaultTradition": "plain"`, `"defaultTradition": "fancy"`},
+		"unknown deck arcana":  {`"aliases": ["Everything"]`, `"arcana": ["trumps"]`},
+		"duplicate deck alias": {`"aliases": ["Everything"]`, `"aliases": ["test-full"]`},
+	}
+
+	for name, c := range cases {
+		data := strings.Replace(validCardSet, c.old, c.new, 1)
+		if data == validCardSet {
+			t.Fatalf("%s: replacement %q not found", name, c.old)
+		}
+		if err := validateCardSets([]*cardSet{testCardSet(t, data)}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// Card and deck IDs must be unique across files too
+	if err := validateCardSets([]*cardSet{testCardSet(t, validCardSet), testCardSet(t, validCardSet)}); err == nil {
+		t.Error("Expected an error for a duplicate card set")
+	}
+}
+
+// loadDeckSchema reads data/decks/deck.schema.json
+func loadDeckSchema(t *testing.T) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile("data/decks/deck.schema.json")
+	if err != nil {
+		t.Fatalf("Failed to read deck.schema.json: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Failed to parse deck.schema.json: %v", err)
+	}
+	return schema
+}
+
+func TestDeckSchema_DeckFiles(t *testing.T) {
+	schema := loadDeckSchema(t)
+	entries, err := deckFiles.ReadDir("data/decks")
+	if err != nil {
+		t.Fatalf("Failed to list data/decks: %v", err)
+	}
+	for _, entry := range entries {
+		data, _ := deckFiles.ReadFile("data/decks/" + entry.Name())
+		var set any
+		if err := json.Unmarshal(data, &set); err != nil {
+			t.Fatalf("Failed to parse %s: %v", entry.Name(), err)
+		}
+		if err := validateSchema(schema, schema, set, entry.Name()); err != nil {
+			t.Errorf("Expected %s to match the schema, got %v", entry.Name(), err)
+		}
+	}
+	if len(entries) != len(cardSets) {
+		t.Errorf("Expected a deck file for each of the %d card sets, got %d", len(cardSets), len(entries))
+	}
+}
+
+func TestDeckSchema_Rejects(t *testing.T) {
+	schema := loadDeckSchema(t)
+	check := func(data string) error {
+		var set any
+		if err := json.Unmarshal([]byte(data), &set); err != nil {
+			t.Fatalf("Failed to parse card set: %v", err)
+		}
+		return validateSchema(schema, schema, set, "test")
+	}
+	if err := check(validCardSet); err != nil {
+		t.Fatalf("Expected the valid card set to match the schema, got %v", err)
+	}
+
+	cases := map[string]struct {
+		old, new string
+	}{
+		"missing cards":     {`"cards": [`, `"cardz": [`},
+		"bad card id":       {`"id": "fool"`, `"id": "The Fool"`},
+		"negative rank":     {`"rank": 0`, `"rank": -1`},
+		"empty image":       {`"image": "cups2.jpg"`, `"image": ""`},
+		"unknown arcana":    {`"arcana": "minor"`, `"arcana": "lesser"`},
+		"unknown property":  {`"image": "fool.jpg"`, `"image": "fool.jpg", "colour": "red"`},
+		"bad rank key":      {`"2": "Two"`, `"two": "Two"`},
+		"bad tradition id":  {`"plain": {`, `"Plain Names": {`},
+		"no decks":          {`"decks": [{"id": "test-full", "name": "Full", "aliases": ["Everything"]}]`, `"decks": []`},
+		"deck without name": {`"name": "Full", `, ``},
+	}
+	for name, c := range cases {
+		data := strings.Replace(validCardSet, c.old, c.new, 1)
+		if data == validCardSet {
+			t.Fatalf("%s: replacement %q not found", name, c.old)
+		}
+		if err := check(data); err == nil {
+			t.Errorf("%s: expected a schema error", name)
+		}
+	}
+}
+
+func TestDecks_ImagesExist(t *testing.T) {
+	for _, set := range cardSets {
+		for _, def := range set.Cards {
+			if _, err := os.Stat(filepath.Join("..", "assets", "images", def.Image)); err != nil {
+				t.Errorf("%s: image for %s: %v", set.ID, def.ID, err)
+			}
+		}
+	}
+}
+
+func TestGetDeck_ByIDAndAlias(t *testing.T) {
+	byID, trad, err := getDeck("tarot-major", "Upright only", "", nil, nil, defaultSource)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if trad.Name != "rws" {
+		t.Errorf("Expected default tradition 'rws', got '%s'", trad.Name)
+	}
+
+	byAlias, _, err := getDeck("Major Arcana only", "Upright only", "", nil, nil, defaultSource)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(byID) != 22 || len(byAlias) != 22 || byID[21].ID != byAlias[21].ID {
+		t.Errorf("Expected the same 22 cards by ID and alias, got %d and %d", len(byID), len(byAlias))
+	}
+
+	if _, _, err := getDeck("tarot-full", "Upright only", "visconti", nil, nil, defaultSource); err != errUnknownTradition {
+		t.Errorf("Expected errUnknownTradition, got %v", err)
+	}
+}
+
+func TestGetDeck_Lenormand(t *testing.T) {
+	deck, trad, err := getDeck("lenormand", "Upright only", "", nil, nil, defaultSource)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if trad.Name != "classic" {
+		t.Errorf("Expected tradition 'classic', got '%s'", trad.Name)
+	}
+	if len(deck) != 36 {
+		t.Fatalf("Expected 36 Lenormand cards, got %d", len(deck))
+	}
+
+	for i, card := range deck {
+		if card.Rank != i+1 || card.Number != strconv.Itoa(i+1) {
+			t.Errorf("Expected card %d at position %d, got %s (%s)", i+1, i, card.Number, card.ID)
+		}
+		if card.Arcana != "" || card.Suit != SuitNone {
+			t.Errorf("Expected no arcana or suit for %s, got %q %q", card.ID, card.Arcana, card.Suit)
+		}
+	}
+	if deck[0].NameSuit != "Rider" || deck[35].NameSuit != "Cross" {
+		t.Errorf("Expected Rider first and Cross last, got %s and %s", deck[0].NameSuit, deck[35].NameSuit)
+	}
+}
+
+func TestGetDeck_PlayingCards(t *testing.T) {
+	deck, _, err := getDeck("52-card deck", "Upright only", "", nil, nil, defaultSource)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(deck) != 52 {
+		t.Fatalf("Expected 52 playing cards, got %d", len(deck))
+	}
+
+	suits := map[Suit]int{}
+	for _, card := range deck {
+		suits[card.Suit]++
+		if card.Rank < 1 || card.Rank > 13 || card.Image != strings.ToUpper(card.ID[:1])+strings.Replace(card.ID[1:], "-", "", 1)+".png" {
+			t.Errorf("Unexpected playing card %+v", card)
+		}
+	}
+	for _, suit := range []Suit{SuitClubs, SuitDiamonds, SuitHearts, SuitSpades} {
+		if suits[suit] != 13 {
+			t.Errorf("Expected 13 %s, got %d", suit, suits[suit])
+		}
+	}
+
+	if ace := deck[0]; ace.ID != "clubs-01" || ace.Number+" "+ace.NameSuit != "Ace of Clubs" {
+		t.Errorf("Expected the Ace of Clubs first, got %s %s", ace.Number, ace.NameSuit)
+	}
+}
+
+func TestDrawHandler_OtherDecks(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	// Tarot translations must not rename Lenormand or playing cards
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Petit Lenormand", "deckReverse": "Upright only", "numCards": 40, "locale": "fr"}`, nil), 200, &drawResp)
+	if len(drawResp.DrawnCards) != 36 || drawResp.Tradition != "classic" {
+		t.Errorf("Expected all 36 cards in the classic tradition, got %d in %s", len(drawResp.DrawnCards), drawResp.Tradition)
+	}
+	for _, card := range drawResp.DrawnCards {
+		if card.NameSuit != cardSets["lenormand"].Traditions["classic"].Titles[card.ID] {
+			t.Errorf("Expected Lenormand name for %s, got '%s'", card.ID, card.NameSuit)
+		}
+	}
+
+	drawResp = drawResponse{}
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "playing-52", "deckReverse": "Upright only", "numCards": 52, "locale": "fr"}`, nil), 200, &drawResp)
+	for _, card := range drawResp.DrawnCards {
+		if card.Rank == 12 && card.Number != "Queen" {
+			t.Errorf("Expected Queen for %s, got '%s'", card.ID, card.Number)
+		}
+	}
+
+	resp, err := drawHandler(apiRequest("POST", "/draw", `{"deckSize": "lenormand", "deckReverse": "Upright only", "tradition": "thoth"}`, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("Expected status 400 for a tarot tradition, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Commit-reveal draws let a client check that the server could not rig a reading.
+//
+//  1. POST /draw/commit returns a commitId and commitment = SHA-256(serverSeed).
+//     The server seed stays secret, so the client learns nothing about the order.
+//  2. POST /draw with commitId and the client's own clientSeed reveals the
+//     server seed and shuffles with SHA-256(serverSeed + ":" + clientSeed).
+//  3. Anyone can check SHA-256(serverSeed) against the commitment and recompute
+//     the shuffle with VerifyDraw or POST /draw/verify.
+//
+// The server seed is derived from the commitId with an HMAC keyed by
+// DRAW_SECRET, so no state is kept between the two calls and the server cannot
+// swap the seed once the commitment has been published. Each commitId records
+// when it was issued and can be drawn with once within commitTTL; otherwise a
+// client could draw again with new client seeds until one dealt the reading it
+// wanted.
+
+type commitResponse struct {
+	CommitID   string `json:"commitId"`
+	Commitment string `json:"commitment"`
+}
+
+type drawProof struct {
+	Commitment string `json:"commitment"`
+	ServerSeed string `json:"serverSeed"`
+	ClientSeed string `json:"clientSeed"`
+}
+
+type verifyRequest struct {
+	deckOptions
+	drawProof
+}
+
+type verifyResponse struct {
+	Verified   bool          `json:"verified"`
+	DrawnCards []Card        `json:"drawnCards"`
+	Shuffle    []shuffleStep `json:"shuffle,omitempty"`
+	Seed       string        `json:"seed,omitempty"`
+	Message    string        `json:"message"`
+}
+
+var (
+	errCommitmentMismatch = errors.New("server seed does not match commitment")
+	errCommitExpired      = errors.New("commitment expired")
+)
+
+// serverSecret keys the derivation of server seeds, deck-state tokens and the
+// card of the day. Without DRAW_SECRET a random key is used, so commitments
+// and tokens only survive within one warm container and each container deals
+// its own card of the day; the terraform requires draw_secret for this reason.
+var serverSecret = loadServerSecret()
+
+func loadServerSecret() []byte {
+	if secret := os.Getenv("DRAW_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	log.Print("no DRAW_SECRET configured, using an ephemeral key")
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return b
+}
+
+// newCommitID returns a fresh commitId: the time it was issued followed by
+// 16 random bytes
+func newCommitID(now time.Time) string {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint64(b, uint64(now.Unix()))
+	if _, err := rand.Read(b[8:]); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// commitExpiry returns when the commitId id stops being accepted, and false if
+// id was not issued by commitHandler
+func commitExpiry(id string) (time.Time, bool) {
+	b, err := hex.DecodeString(id)
+	if err != nil || len(b) != 24 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(b)), 0).Add(commitTTL), true
+}
+
+// validCommitID reports whether id has the shape of an id issued by commitHandler
+func validCommitID(id string) bool {
+	_, ok := commitExpiry(id)
+	return ok
+}
+
+// claimCommitment records that a draw has revealed the server seed behind
+// commitID, which must have been validated. It fails if the commitment has
+// expired or was already drawn with.
+func claimCommitment(commitID string, now time.Time) error {
+	expires, _ := commitExpiry(commitID)
+	// Allow for a little clock skew between containers, but no more
+	if now.After(expires) || expires.Sub(now) > commitTTL+time.Minute {
+		return errCommitExpired
+	}
+	return usedCommits.Use(commitID, expires)
+}
+
+// serverSeedFor derives the secret server seed committed to by commitID
+func serverSeedFor(commitID string) string {
+	mac := hmac.New(sha256.New, serverSecret)
+	mac.Write([]byte("server-seed:" + commitID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// commitmentFor returns the published hash of a server seed
+func commitmentFor(serverSeed string) string {
+	sum := sha256.Sum256([]byte(serverSeed))
+	return hex.EncodeToString(sum[:])
+}
+
+// combinedSeed mixes the server and client seeds into the seed used to shuffle
+func combinedSeed(serverSeed, clientSeed string) string {
+	sum := sha256.Sum256([]byte(serverSeed + ":" + clientSeed))
+	return hex.EncodeToString(sum[:])
+}
+
+// revealProof returns the proof for a draw made against commitID
+func revealProof(commitID, clientSeed string) *drawProof {
+	serverSeed := serverSeedFor(commitID)
+	return &drawProof{
+		Commitment: commitmentFor(serverSeed),
+		ServerSeed: serverSeed,
+		ClientSeed: clientSeed,
+	}
+}
+
+// VerifyDraw checks proof against its commitment and recomputes the cards a
+// commit-reveal draw must have produced. opts are the deck options sent with
+// the original draw request, including any spread, significator, filter,
+// reversal, shuffle, copies or replacement. Image fields hold the bare file
+// names rather than CloudFront URLs.
+func VerifyDraw(proof drawProof, opts deckOptions) ([]Card, error) {
+	dealt, err := verifiedDeal(proof, opts)
+	if err != nil {
+		return nil, err
+	}
+	return dealt.cards, nil
+}
+
+// verifiedDeal checks proof against its commitment and deals the reading the
+// draw must have produced with opts
+func verifiedDeal(proof drawProof, opts deckOptions) (*dealing, error) {
+	if commitmentFor(proof.ServerSeed) != proof.Commitment {
+		return nil, errCommitmentMismatch
+	}
+	return dealCards(opts, newSeededSource(combinedSeed(proof.ServerSeed, proof.ClientSeed)))
+}
+
+// commitHandler issues a new commitment for a later commit-reveal draw
+func commitHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "POST" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_post_only"))
+	}
+
+	commitID := newCommitID(time.Now())
+	return jsonResponse(http.StatusOK, commitResponse{
+		CommitID:   commitID,
+		Commitment: commitmentFor(serverSeedFor(commitID)),
+	})
+}
+
+// verifyHandler recomputes a commit-reveal draw from its revealed seeds
+func verifyHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "POST" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_post_only"))
+	}
+
+	var verifyReq verifyRequest
+	if err := decodeRequest(req, &verifyReq); err != nil {
+		return decodeErrorResult(loc, err)
+	}
+
+	if verifyReq.DeckSize == "" || verifyReq.DeckReverse == "" || verifyReq.ServerSeed == "" || verifyReq.Commitment == "" {
+		return errorResult(http.StatusBadRequest, "missing_parameters", loc.message("missing_verify_parameters"))
+	}
+
+	dealt, err := verifiedDeal(verifyReq.drawProof, verifyReq.deckOptions)
+	switch {
+	case errors.Is(err, errCommitmentMismatch):
+		return jsonResponse(http.StatusOK, verifyResponse{
+			Verified:   false,
+			DrawnCards: []Card{},
+			Message:    loc.message("commitment_mismatch"),
+		})
+	case err != nil:
+		return dealErrorResult(loc, err)
+	}
+
+	drawnCards := dealt.cards
+	for i := range drawnCards {
+		drawnCards[i].Image = cloudFrontURL + "/images/" + drawnCards[i].Image
+	}
+	loc.localizeCards(drawnCards, dealt.tradition.Name)
+
+	return jsonResponse(http.StatusOK, verifyResponse{
+		Verified:   true,
+		DrawnCards: drawnCards,
+		Shuffle:    dealt.shuffle,
+		Seed:       combinedSeed(verifyReq.ServerSeed, verifyReq.ClientSeed),
+	})
+}
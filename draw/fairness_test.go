@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommitHandler_IssuesCommitment(t *testing.T) {
+	var commit commitResponse
+	call(t, apiRequest("POST", "/draw/commit", "", nil), 200, &commit)
+
+	if !validCommitID(commit.CommitID) {
+		t.Errorf("Expected valid commitId, got '%s'", commit.CommitID)
+	}
+	if len(commit.Commitment) != 64 {
+		t.Errorf("Expected SHA-256 hex commitment, got '%s'", commit.Commitment)
+	}
+	if strings.Contains(commit.Commitment, serverSeedFor(commit.CommitID)) {
+		t.Error("Commitment must not reveal the server seed")
+	}
+}
+
+func TestDrawHandler_CommitReveal(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var commit commitResponse
+	call(t, apiRequest("POST", "/draw/commit", "", nil), 200, &commit)
+	body := `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 10, "commitId": "` +
+		commit.CommitID + `", "clientSeed": "my lucky seed"}`
+
+	resp, err := handleRequest(apiRequest("POST", "/draw", body, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var drawResp drawResponse
+	if err := json.Unmarshal([]byte(resp.Body), &drawResp); err != nil {
+		t.Fatalf("Failed to parse draw response: %v", err)
+	}
+
+	if drawResp.Proof == nil {
+		t.Fatal("Expected proof in commit-reveal response")
+	}
+	if drawResp.Proof.Commitment != commit.Commitment {
+		t.Errorf("Expected commitment '%s', got '%s'", commit.Commitment, drawResp.Proof.Commitment)
+	}
+	if drawResp.Proof.ClientSeed != "my lucky seed" {
+		t.Errorf("Expected client seed to be echoed, got '%s'", drawResp.Proof.ClientSeed)
+	}
+
+	verified, err := VerifyDraw(*drawResp.Proof, deckOptions{DeckSize: "Full Deck", DeckReverse: "Upright and reversed", NumCards: 10})
+	if err != nil {
+		t.Fatalf("Expected draw to verify, got %v", err)
+	}
+	for i := range verified {
+		got := drawResp.DrawnCards[i]
+		if verified[i].NameSuit != got.NameSuit || verified[i].Number != got.Number || verified[i].Reversed != got.Reversed {
+			t.Errorf("Card %d differs from recomputed draw: %+v vs %+v", i, got, verified[i])
+		}
+	}
+}
+
+func TestDrawHandler_CommitRequiresClientSeed(t *testing.T) {
+	var commit commitResponse
+	call(t, apiRequest("POST", "/draw/commit", "", nil), 200, &commit)
+	body := `{"deckSize": "Full Deck", "deckReverse": "Upright only", "commitId": "` + commit.CommitID + `"}`
+
+	resp, err := drawHandler(apiRequest("POST", "/draw", body, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestDrawHandler_InvalidCommitID(t *testing.T) {
+	body := `{"deckSize": "Full Deck", "deckReverse": "Upright only", "commitId": "nope", "clientSeed": "x"}`
+
+	resp, err := drawHandler(apiRequest("POST", "/draw", body, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var errorResp errorResponse
+	if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+		t.Fatalf("Failed to parse error response: %v", err)
+	}
+	if errorResp.Error != "invalid_commit" {
+		t.Errorf("Expected error 'invalid_commit', got '%s'", errorResp.Error)
+	}
+}
+
+func TestDrawHandler_CommitSingleUse(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var commit commitResponse
+	call(t, apiRequest("POST", "/draw/commit", "", nil), 200, &commit)
+	draw := func(clientSeed string) string {
+		return `{"deckSize": "Full Deck", "deckReverse": "Upright only", "commitId": "` + commit.CommitID + `", "clientSeed": "` + clientSeed + `"}`
+	}
+	call(t, apiRequest("POST", "/draw", draw("first"), nil), 200, nil)
+
+	// Drawing again with another client seed would let the caller pick a reading
+	var errorResp errorResponse
+	call(t, apiRequest("POST", "/draw", draw("second"), nil), 400, &errorResp)
+	if errorResp.Error != "invalid_commit" || errorResp.Message != newLocalizer("en").message("commit_used") {
+		t.Errorf("Expected a used commitment to be refused, got %+v", errorResp)
+	}
+
+	// A commitment issued more than commitTTL ago has expired
+	expired := newCommitID(time.Now().Add(-commitTTL - time.Minute))
+	errorResp = errorResponse{}
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "commitId": "`+expired+`", "clientSeed": "x"}`, nil), 400, &errorResp)
+	if errorResp.Error != "invalid_commit" {
+		t.Errorf("Expected an expired commitment to be refused, got %+v", errorResp)
+	}
+
+	// A draw that fails does not use up the commitment
+	call(t, apiRequest("POST", "/draw/commit", "", nil), 200, &commit)
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Tiny Deck", "deckReverse": "Upright only", "commitId": "`+commit.CommitID+`", "clientSeed": "x"}`, nil), 400, nil)
+	call(t, apiRequest("POST", "/draw", draw("x"), nil), 200, nil)
+}
+
+func TestVerifyDraw_RejectsTamperedSeed(t *testing.T) {
+	proof := revealProof(newSeed(), "client")
+	proof.ServerSeed = strings.Repeat("0", 64)
+
+	if _, err := VerifyDraw(*proof, deckOptions{DeckSize: "Full Deck", DeckReverse: "Upright only", NumCards: 5}); err != errCommitmentMismatch {
+		t.Errorf("Expected commitment mismatch, got %v", err)
+	}
+}
+
+func TestVerifyHandler(t *testing.T) {
+	proof := revealProof(newSeed(), "client")
+	body, _ := json.Marshal(verifyRequest{
+		deckOptions: deckOptions{DeckSize: "Major Arcana only", DeckReverse: "Upright only", NumCards: 3},
+		drawProof:   *proof,
+	})
+
+	resp, err := handleRequest(apiRequest("POST", "/draw/verify", string(body), nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var verifyResp verifyResponse
+	if err := json.Unmarshal([]byte(resp.Body), &verifyResp); err != nil {
+		t.Fatalf("Failed to parse verify response: %v", err)
+	}
+	if !verifyResp.Verified {
+		t.Errorf("Expected draw to verify, got message '%s'", verifyResp.Message)
+	}
+	if len(verifyResp.DrawnCards) != 3 {
+		t.Errorf("Expected 3 cards, got %d", len(verifyResp.DrawnCards))
+	}
+
+	proof.Commitment = strings.Repeat("f", 64)
+	body, _ = json.Marshal(verifyRequest{
+		deckOptions: deckOptions{DeckSize: "Major Arcana only", DeckReverse: "Upright only", NumCards: 3},
+		drawProof:   *proof,
+	})
+
+	resp, _ = handleRequest(apiRequest("POST", "/draw/verify", string(body), nil))
+	verifyResp = verifyResponse{}
+	if err := json.Unmarshal([]byte(resp.Body), &verifyResp); err != nil {
+		t.Fatalf("Failed to parse verify response: %v", err)
+	}
+	if verifyResp.Verified {
+		t.Error("Expected mismatched commitment to fail verification")
+	}
+}
+
+func TestVerifyHandler_CustomSpread(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var commit commitResponse
+	call(t, apiRequest("POST", "/draw/commit", "", nil), 200, &commit)
+	custom := `{"positions": [{"name": "Card"}, {"name": "Lesson", "rule": {"arcana": "major"}}]}`
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "commitId": "`+commit.CommitID+`", "clientSeed": "client", "customSpread": `+custom+`}`, nil), 200, &drawResp)
+
+	body, _ := json.Marshal(map[string]any{
+		"deckSize":     "Full Deck",
+		"deckReverse":  "Upright and reversed",
+		"customSpread": json.RawMessage(custom),
+		"commitment":   drawResp.Proof.Commitment,
+		"serverSeed":   drawResp.Proof.ServerSeed,
+		"clientSeed":   drawResp.Proof.ClientSeed,
+	})
+	resp, err := handleRequest(apiRequest("POST", "/draw/verify", string(body), nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var verifyResp verifyResponse
+	if err := json.Unmarshal([]byte(resp.Body), &verifyResp); err != nil {
+		t.Fatalf("Failed to parse verify response: %v", err)
+	}
+	if !verifyResp.Verified || len(verifyResp.DrawnCards) != 2 {
+		t.Fatalf("Expected 2 verified cards, got %d (%s)", len(verifyResp.DrawnCards), verifyResp.Message)
+	}
+	for i, card := range verifyResp.DrawnCards {
+		if card.ID != drawResp.DrawnCards[i].ID || card.Position.Name != drawResp.DrawnCards[i].Position.Name {
+			t.Errorf("Expected %s in position %d, got %s", drawResp.DrawnCards[i].ID, i+1, card.ID)
+		}
+	}
+}
+
+func TestVerifyDraw_SpreadAndShuffle(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	options := `"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "spread": "celtic-cross", "significator": "cups-13",
+		"reversal": {"probability": 0.3, "policy": "majors"}, "shuffle": {"strategy": "riffle", "passes": 3, "cut": true}`
+	var opts deckOptions
+	if err := json.Unmarshal([]byte(`{`+options+`}`), &opts); err != nil {
+		t.Fatalf("Failed to parse options: %v", err)
+	}
+
+	var commit commitResponse
+	call(t, apiRequest("POST", "/draw/commit", "", nil), 200, &commit)
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{`+options+`, "commitId": "`+commit.CommitID+`", "clientSeed": "client"}`, nil), 200, &drawResp)
+
+	verified, err := VerifyDraw(*drawResp.Proof, opts)
+	if err != nil {
+		t.Fatalf("Expected draw to verify, got %v", err)
+	}
+
+	// The verify endpoint takes the same options alongside the proof
+	proof, _ := json.Marshal(drawResp.Proof)
+	body := `{` + options + `, ` + strings.TrimPrefix(string(proof), "{")
+	var verifyResp verifyResponse
+	call(t, apiRequest("POST", "/draw/verify", body, nil), 200, &verifyResp)
+	if !verifyResp.Verified || len(verifyResp.Shuffle) != len(drawResp.Shuffle) {
+		t.Fatalf("Expected the draw and its shuffle steps to verify, got %+v", verifyResp)
+	}
+
+	if len(verified) != len(drawResp.DrawnCards) || len(verifyResp.DrawnCards) != len(drawResp.DrawnCards) {
+		t.Fatalf("Expected %d cards, got %d and %d", len(drawResp.DrawnCards), len(verified), len(verifyResp.DrawnCards))
+	}
+	for i, want := range drawResp.DrawnCards {
+		for _, got := range []Card{verified[i], verifyResp.DrawnCards[i]} {
+			if got.ID != want.ID || got.Reversed != want.Reversed || got.Position.Name != want.Position.Name {
+				t.Errorf("Card %d: expected %s (reversed %v) at %s, got %s (reversed %v) at %s",
+					i, want.ID, want.Reversed, want.Position.Name, got.ID, got.Reversed, got.Position.Name)
+			}
+		}
+	}
+}
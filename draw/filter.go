@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// deckFilter narrows a deck before it is shuffled. A card stays in the deck
+// only if it meets every condition that is set:
+//
+//   - Suits keeps only cards of these suits, dropping unsuited cards
+//   - ExcludeSuits drops cards of these suits
+//   - Only keeps just the "courts" or just the "pips" (suited cards below the
+//     courts, Ace to Ten in tarot)
+//   - MinRank and MaxRank bound the rank, or the number of unsuited cards
+//   - Exclude drops the cards with these IDs
+type deckFilter struct {
+	Suits        []Suit   `json:"suits,omitempty"`
+	ExcludeSuits []Suit   `json:"excludeSuits,omitempty"`
+	Only         string   `json:"only,omitempty"`
+	MinRank      *int     `json:"minRank,omitempty"`
+	MaxRank      *int     `json:"maxRank,omitempty"`
+	Exclude      []string `json:"exclude,omitempty"`
+}
+
+const (
+	onlyCourts = "courts"
+	onlyPips   = "pips"
+)
+
+var errInvalidFilter = errors.New("invalid filter")
+
+// tooFewCardsError reports a filter that leaves fewer cards than requested
+type tooFewCardsError struct {
+	remaining, requested int
+}
+
+func (e *tooFewCardsError) Error() string {
+	return fmt.Sprintf("filter leaves %d cards, %d requested", e.remaining, e.requested)
+}
+
+// validate checks the filter against the card set it is applied to
+func (f *deckFilter) validate(set *cardSet) error {
+	for _, suit := range append(slices.Clone(f.Suits), f.ExcludeSuits...) {
+		if !slices.ContainsFunc(set.Cards, func(def cardDefinition) bool { return def.Suit == suit }) {
+			return errInvalidFilter
+		}
+	}
+	if f.Only != "" && f.Only != onlyCourts && f.Only != onlyPips {
+		return errInvalidFilter
+	}
+	if f.MinRank != nil && f.MaxRank != nil && *f.MinRank > *f.MaxRank {
+		return errInvalidFilter
+	}
+	for _, id := range f.Exclude {
+		if !slices.ContainsFunc(set.Cards, func(def cardDefinition) bool { return def.ID == id }) {
+			return errInvalidFilter
+		}
+	}
+	return nil
+}
+
+// apply returns the cards of set that the filter keeps
+func (f *deckFilter) apply(cards []Card, set *cardSet) []Card {
+	var kept []Card
+	for _, card := range cards {
+		if f.keeps(card, set) {
+			kept = append(kept, card)
+		}
+	}
+	return kept
+}
+
+func (f *deckFilter) keeps(card Card, set *cardSet) bool {
+	if len(f.Suits) > 0 && !slices.Contains(f.Suits, card.Suit) {
+		return false
+	}
+	if card.Suit != SuitNone && slices.Contains(f.ExcludeSuits, card.Suit) {
+		return false
+	}
+
+	court := card.Suit != SuitNone && slices.Contains(set.CourtRanks, card.Rank)
+	pip := card.Suit != SuitNone && !court
+	if (f.Only == onlyCourts && !court) || (f.Only == onlyPips && !pip) {
+		return false
+	}
+
+	if (f.MinRank != nil && card.Rank < *f.MinRank) || (f.MaxRank != nil && card.Rank > *f.MaxRank) {
+		return false
+	}
+	return !slices.Contains(f.Exclude, card.ID)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestGetDeck_Filter(t *testing.T) {
+	cases := []struct {
+		name   string
+		deck   string
+		filter deckFilter
+		want   int
+	}{
+		{"suits", "Full Deck", deckFilter{Suits: []Suit{SuitCups, SuitWands}}, 28},
+		{"exclude suits", "Full Deck", deckFilter{ExcludeSuits: []Suit{SuitSwords}}, 64},
+		{"courts", "Full Deck", deckFilter{Only: onlyCourts}, 16},
+		{"pips", "Full Deck", deckFilter{Only: onlyPips}, 40},
+		{"cup courts", "Full Deck", deckFilter{Suits: []Suit{SuitCups}, Only: onlyCourts}, 4},
+		{"rank range", "Minor Arcana only", deckFilter{MinRank: intPtr(2), MaxRank: intPtr(5)}, 16},
+		{"major numbers", "Major Arcana only", deckFilter{MaxRank: intPtr(10)}, 11},
+		{"exclude ids", "Major Arcana only", deckFilter{Exclude: []string{"major-13-death", "major-16-tower"}}, 20},
+		{"playing card courts", "playing-52", deckFilter{Only: onlyCourts, ExcludeSuits: []Suit{SuitClubs}}, 9},
+	}
+
+	for _, c := range cases {
+		deck, _, err := getDeck(c.deck, "Upright only", "", &c.filter, nil, defaultSource)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", c.name, err)
+			continue
+		}
+		if len(deck) != c.want {
+			t.Errorf("%s: expected %d cards, got %d", c.name, c.want, len(deck))
+		}
+	}
+}
+
+func TestGetDeck_InvalidFilter(t *testing.T) {
+	cases := map[string]deckFilter{
+		"unknown suit":       {Suits: []Suit{"stars"}},
+		"suit of other deck": {ExcludeSuits: []Suit{SuitHearts}},
+		"unknown only":       {Only: "trumps"},
+		"inverted range":     {MinRank: intPtr(9), MaxRank: intPtr(3)},
+		"unknown card":       {Exclude: []string{"hearts-01"}},
+	}
+	for name, filter := range cases {
+		if _, _, err := getDeck("Full Deck", "Upright only", "", &filter, nil, defaultSource); err != errInvalidFilter {
+			t.Errorf("%s: expected errInvalidFilter, got %v", name, err)
+		}
+	}
+}
+
+func TestDrawHandler_Filter(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 5,
+		"filter": {"suits": ["cups"], "only": "pips", "exclude": ["cups-01"]}}`, nil), 200, &drawResp)
+	if len(drawResp.DrawnCards) != 5 {
+		t.Fatalf("Expected 5 cards, got %d", len(drawResp.DrawnCards))
+	}
+	for _, card := range drawResp.DrawnCards {
+		if card.Suit != SuitCups || card.Rank < 2 || card.Rank > 10 {
+			t.Errorf("Expected a cups pip other than the Ace, got %s", card.ID)
+		}
+	}
+
+	cases := []struct {
+		body, code, message string
+	}{
+		{`{"deckSize": "Full Deck", "deckReverse": "Upright only", "filter": {"only": "trumps"}}`, "invalid_filter", ""},
+		{`{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 5, "filter": {"suits": ["cups"], "only": "courts"}}`,
+			"not_enough_cards", "The filter leaves 4 cards but 5 were requested"},
+		{`{"deckSize": "Full Deck", "deckReverse": "Upright only", "spread": "celtic-cross", "filter": {"only": "courts", "maxRank": 11}}`,
+			"not_enough_cards", "The filter leaves 4 cards but 10 were requested"},
+	}
+	for _, c := range cases {
+		resp, err := drawHandler(apiRequest("POST", "/draw", c.body, nil))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var errorResp errorResponse
+		if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+			t.Fatalf("Failed to parse error response: %v", err)
+		}
+		if resp.StatusCode != 400 || errorResp.Error != c.code {
+			t.Errorf("Expected 400 %s, got %d %s", c.code, resp.StatusCode, errorResp.Error)
+		}
+		if c.message != "" && errorResp.Message != c.message {
+			t.Errorf("Expected message '%s', got '%s'", c.message, errorResp.Message)
+		}
+	}
+}
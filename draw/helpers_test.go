@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// apiRequest builds the API Gateway request for method and path, with an
+// optional body and query string
+func apiRequest(method, path, body string, query map[string]string) events.APIGatewayV2HTTPRequest {
+	return events.APIGatewayV2HTTPRequest{
+		RawPath:               path,
+		QueryStringParameters: query,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method: method,
+				Path:   path,
+			},
+		},
+		Body: body,
+	}
+}
+
+// call sends req through handleRequest, fails the test unless it answers
+// with status, and parses the JSON body into v unless v is nil
+func call(t *testing.T, req events.APIGatewayV2HTTPRequest, status int, v any) events.APIGatewayV2HTTPResponse {
+	t.Helper()
+
+	resp, err := handleRequest(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != status {
+		t.Fatalf("Expected status %d, got %d: %s", status, resp.StatusCode, resp.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal([]byte(resp.Body), v); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// catalog holds the translations for one locale. Card names are keyed by
+// tradition, as each tradition names the cards its own way; any card name
+// missing from a catalog, including every name of a tradition the catalog
+// does not list, keeps the English name the tradition gives it. Messages are
+// keyed by message ID and fall back to the English catalog.
+type catalog struct {
+	Traditions map[string]*cardNames `json:"traditions"`
+	Messages   map[string]string     `json:"messages"`
+}
+
+// cardNames translates the names one tradition gives its cards. Cards are
+// keyed by card ID, ranks by minor arcana rank and suits by Suit.
+type cardNames struct {
+	Cards map[string]string `json:"cards"`
+	Ranks map[string]string `json:"ranks"`
+	Suits map[Suit]string   `json:"suits"`
+}
+
+const defaultLocale = "en"
+
+//go:embed data/locales/*.json
+var localeFiles embed.FS
+
+// catalogs holds every embedded catalog, keyed by language code
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]*catalog {
+	entries, err := localeFiles.ReadDir("data/locales")
+	if err != nil {
+		panic("reading data/locales: " + err.Error())
+	}
+
+	loaded := map[string]*catalog{}
+	for _, entry := range entries {
+		data, err := localeFiles.ReadFile("data/locales/" + entry.Name())
+		if err != nil {
+			panic("reading data/locales/" + entry.Name() + ": " + err.Error())
+		}
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			panic("invalid data/locales/" + entry.Name() + ": " + err.Error())
+		}
+		loaded[strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))] = &c
+	}
+
+	if loaded[defaultLocale] == nil {
+		panic("missing data/locales/" + defaultLocale + ".json")
+	}
+	return loaded
+}
+
+// localizer translates card names and messages into one locale
+type localizer struct {
+	locale  string
+	catalog *catalog
+}
+
+// newLocalizer returns a localizer for tag (e.g. "fr" or "fr-CA"), falling
+// back to English when the language is not supported
+func newLocalizer(tag string) localizer {
+	lang := baseLanguage(tag)
+	if c, ok := catalogs[lang]; ok {
+		return localizer{locale: lang, catalog: c}
+	}
+	return localizer{locale: defaultLocale, catalog: catalogs[defaultLocale]}
+}
+
+// baseLanguage reduces a language tag such as "fr-CA" to "fr"
+func baseLanguage(tag string) string {
+	lang := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// requestLocalizer picks a localizer from the request's Accept-Language header
+func requestLocalizer(req events.APIGatewayV2HTTPRequest) localizer {
+	return newLocalizer(negotiateLocale(header(req, "Accept-Language")))
+}
+
+// negotiateLocale returns the supported language the Accept-Language value
+// prefers most, or the default locale
+func negotiateLocale(acceptLanguage string) string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+
+	var langs []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.TrimSpace(fields[0])
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, weighted{lang, q})
+		}
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	for _, w := range langs {
+		if lang := baseLanguage(w.lang); catalogs[lang] != nil {
+			return lang
+		}
+	}
+	return defaultLocale
+}
+
+// header returns the named request header, ignoring case
+func header(req events.APIGatewayV2HTTPRequest, name string) string {
+	for key, value := range req.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// message returns the translation of message id
+func (l localizer) message(id string) string {
+	if m, ok := l.catalog.Messages[id]; ok {
+		return m
+	}
+	return catalogs[defaultLocale].Messages[id]
+}
+
+// localizeCards translates the display names of cards named by the tradition
+// trad in place
+func (l localizer) localizeCards(cards []Card, trad string) {
+	names, ok := l.catalog.Traditions[trad]
+	if !ok {
+		return
+	}
+	for i, card := range cards {
+		if name, ok := names.Cards[card.ID]; ok {
+			cards[i].NameSuit = name
+		}
+		// Catalog ranks name the tarot ranks, so leave other card sets alone
+		if card.Arcana != ArcanaMinor {
+			continue
+		}
+		if rank, ok := names.Ranks[strconv.Itoa(card.Rank)]; ok {
+			cards[i].Number = rank
+		}
+		if suit, ok := names.Suits[card.Suit]; ok {
+			cards[i].NameSuit = suit
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCatalogs_Complete(t *testing.T) {
+	for _, lang := range []string{"en", "fr", "es", "de", "it"} {
+		if catalogs[lang] == nil {
+			t.Errorf("Missing catalog for %s", lang)
+		}
+	}
+
+	english := catalogs[defaultLocale]
+	for lang, c := range catalogs {
+		for id := range english.Messages {
+			if c.Messages[id] == "" {
+				t.Errorf("%s: missing message '%s'", lang, id)
+			}
+		}
+		if lang == defaultLocale {
+			continue
+		}
+
+		for _, trad := range []string{"rws", "marseille"} {
+			names := c.Traditions[trad]
+			if names == nil {
+				t.Fatalf("%s: missing %s card names", lang, trad)
+			}
+			for _, card := range tarotDeck("tarot-major", trad) {
+				if names.Cards[card.ID] == "" {
+					t.Errorf("%s: missing %s name for %s", lang, trad, card.ID)
+				}
+			}
+			if len(names.Ranks) != 14 {
+				t.Errorf("%s: expected 14 %s ranks, got %d", lang, trad, len(names.Ranks))
+			}
+			for _, suit := range []Suit{SuitCups, SuitPentacles, SuitSwords, SuitWands} {
+				if names.Suits[suit] == "" {
+					t.Errorf("%s: missing %s suit %s", lang, trad, suit)
+				}
+			}
+		}
+	}
+}
+
+func TestCatalogs_TraditionNames(t *testing.T) {
+	// The RWS deck has a High Priestess, a Hierophant and Pentacles where the
+	// Marseille deck has a Papess, a Pope and Coins
+	for lang, c := range catalogs {
+		if lang == defaultLocale {
+			continue
+		}
+		rws, marseille := c.Traditions["rws"], c.Traditions["marseille"]
+		for _, id := range []string{"major-02-high-priestess", "major-05-hierophant"} {
+			if rws.Cards[id] == marseille.Cards[id] {
+				t.Errorf("%s: expected different rws and marseille names for %s, got '%s' for both", lang, id, rws.Cards[id])
+			}
+		}
+		if rws.Suits[SuitPentacles] == marseille.Suits[SuitPentacles] {
+			t.Errorf("%s: expected different rws and marseille names for pentacles, got '%s' for both", lang, rws.Suits[SuitPentacles])
+		}
+	}
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "numCards": 22, "tradition": "marseille", "locale": "fr"}`, nil), 200, &drawResp)
+	for _, card := range drawResp.DrawnCards {
+		if card.ID == "major-02-high-priestess" && card.NameSuit != "La Papesse" {
+			t.Errorf("Expected 'La Papesse' in the French Marseille deck, got '%s'", card.NameSuit)
+		}
+	}
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	cases := map[string]string{
+		"":                                   "en",
+		"fr":                                 "fr",
+		"fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5": "fr",
+		"ja, de;q=0.5":                       "de",
+		"en;q=0.4, it;q=0.8":                 "it",
+		"pt-BR, *;q=0.1":                     "en",
+		"es;q=0, de":                         "de",
+	}
+	for header, want := range cases {
+		if got := negotiateLocale(header); got != want {
+			t.Errorf("negotiateLocale(%q) = %s, expected %s", header, got, want)
+		}
+	}
+}
+
+func TestDrawHandler_LocaleField(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "numCards": 100, "seed": "fr", "locale": "fr-FR"}`, nil), 200, &drawResp)
+	if drawResp.Locale != "fr" {
+		t.Errorf("Expected locale 'fr', got '%s'", drawResp.Locale)
+	}
+	if drawResp.Message != "Il n'y a plus de cartes à afficher." {
+		t.Errorf("Expected French message, got '%s'", drawResp.Message)
+	}
+	for _, card := range drawResp.DrawnCards {
+		if card.NameSuit != catalogs["fr"].Traditions["rws"].Cards[card.ID] {
+			t.Errorf("Expected French name for %s, got '%s'", card.ID, card.NameSuit)
+		}
+	}
+}
+
+func TestDrawHandler_AcceptLanguage(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	req := apiRequest("POST", "/draw", `{"deckSize": "Minor Arcana only", "deckReverse": "Upright only", "numCards": 5}`, nil)
+	req.Headers = map[string]string{"accept-language": "de-DE,de;q=0.9,en;q=0.5"}
+	var drawResp drawResponse
+	call(t, req, 200, &drawResp)
+	if drawResp.Locale != "de" {
+		t.Errorf("Expected locale 'de', got '%s'", drawResp.Locale)
+	}
+	for _, card := range drawResp.DrawnCards {
+		if card.NameSuit != catalogs["de"].Traditions["rws"].Suits[card.Suit] {
+			t.Errorf("Expected German suit for %s, got '%s'", card.ID, card.NameSuit)
+		}
+	}
+
+	// Errors are localized too
+	req.Body = "invalid json"
+	var errorResp errorResponse
+	call(t, req, 400, &errorResp)
+	if errorResp.Error != "invalid_request" || errorResp.Message != "Ungültiges JSON im Anfragetext" {
+		t.Errorf("Expected German error message, got %+v", errorResp)
+	}
+}
+
+func TestDrawHandler_UnsupportedLocaleFallsBack(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "numCards": 3, "locale": "xx"}`, nil), 200, &drawResp)
+	if drawResp.Locale != "en" {
+		t.Errorf("Expected fallback to 'en', got '%s'", drawResp.Locale)
+	}
+	for _, card := range drawResp.DrawnCards {
+		if card.NameSuit != tarotTradition("rws").Titles[card.ID] {
+			t.Errorf("Expected English name for %s, got '%s'", card.ID, card.NameSuit)
+		}
+	}
+}
+
+func TestDrawHandler_LocaleKeepsTraditionNames(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	// The catalogs only translate the rws names, so a Thoth reading in German
+	// keeps Thoth's own names rather than the German rws ones
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 78, "tradition": "thoth", "locale": "de"}`, nil), 200, &drawResp)
+	if drawResp.Locale != "de" || drawResp.Tradition != "thoth" {
+		t.Fatalf("Expected a German Thoth reading, got %s and %s", drawResp.Locale, drawResp.Tradition)
+	}
+	thoth := map[string]Card{}
+	for _, card := range tarotDeck("tarot-full", "thoth") {
+		thoth[card.ID] = card
+	}
+	for _, card := range drawResp.DrawnCards {
+		if want := thoth[card.ID]; card.NameSuit != want.NameSuit || card.Number != want.Number {
+			t.Errorf("Expected %s to keep its Thoth name %s %s, got %s %s", card.ID, want.Number, want.NameSuit, card.Number, card.NameSuit)
+		}
+	}
+}
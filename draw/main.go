@@ -1,31 +1,39 @@
 package main
 
 import (
-	"crypto/rand"
 	"encoding/json"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
-type tarotDeck struct {
-	Number   string `json:"number"`
-	NameSuit string `json:"nameSuit"`
-	Reversed string `json:"reversed"`
-	Image    string `json:"image"`
-}
-
 type drawRequest struct {
-	DeckSize    string `json:"deckSize"`
-	DeckReverse string `json:"deckReverse"`
-	NumCards    int    `json:"numCards"`
+	deckOptions
+	Seed            string `json:"seed,omitempty"`
+	CommitID        string `json:"commitId,omitempty"`
+	ClientSeed      string `json:"clientSeed,omitempty"`
+	IncludeMeanings bool   `json:"includeMeanings,omitempty"`
+	Locale          string `json:"locale,omitempty"`
+	// Format renders the reading as json, text, markdown, html or csv,
+	// overriding the Accept header
+	Format string `json:"format,omitempty"`
 }
 
 type drawResponse struct {
-	DrawnCards []tarotDeck `json:"drawnCards"`
-	Message    string      `json:"message"`
+	DrawnCards []Card        `json:"drawnCards"`
+	Message    string        `json:"message"`
+	Seed       string        `json:"seed"`
+	Locale     string        `json:"locale"`
+	Tradition  string        `json:"tradition"`
+	Spread     string        `json:"spread,omitempty"`
+	Shuffle    []shuffleStep `json:"shuffle,omitempty"`
+	DeckState  string        `json:"deckState,omitempty"`
+	Proof      *drawProof    `json:"proof,omitempty"`
+	Receipt    *drawReceipt  `json:"receipt,omitempty"`
 }
 
 type errorResponse struct {
@@ -38,245 +46,254 @@ var (
 )
 
 func main() {
-	lambda.Start(drawHandler)
+	lambda.Start(handleEvent)
 }
 
-func drawHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
-	// CORS headers for all responses
-	corsHeaders := map[string]string{
+// handleEvent decodes an API Gateway event and passes it to handleRequest.
+// The HTTP API sends payload format 2.0 events, but REST APIs and the
+// dev_tooling test events use format 1.0, which carries the method and path
+// in httpMethod and path, so those are converted first.
+func handleEvent(event json.RawMessage) (events.APIGatewayV2HTTPResponse, error) {
+	var format struct {
+		Version    string `json:"version"`
+		HTTPMethod string `json:"httpMethod"`
+	}
+	if err := json.Unmarshal(event, &format); err != nil {
+		return events.APIGatewayV2HTTPResponse{}, err
+	}
+
+	if format.Version != "2.0" && format.HTTPMethod != "" {
+		var proxyReq events.APIGatewayProxyRequest
+		if err := json.Unmarshal(event, &proxyReq); err != nil {
+			return events.APIGatewayV2HTTPResponse{}, err
+		}
+		return handleRequest(fromProxyRequest(proxyReq))
+	}
+
+	var req events.APIGatewayV2HTTPRequest
+	if err := json.Unmarshal(event, &req); err != nil {
+		return events.APIGatewayV2HTTPResponse{}, err
+	}
+	return handleRequest(req)
+}
+
+// handleRequest dispatches each API Gateway request to the handler for its path
+func handleRequest(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	path := requestPath(req)
+	switch {
+	case path == "/sessions" || strings.HasPrefix(path, "/sessions/"):
+		return sessionsHandler(req, path)
+	case path == "/cards" || strings.HasPrefix(path, "/cards/"):
+		return cardsHandler(req, path)
+	}
+
+	switch path {
+	case "/draw/commit":
+		return commitHandler(req)
+	case "/draw/verify":
+		return verifyHandler(req)
+	case "/draw/clarify":
+		return clarifyHandler(req)
+	case "/draw/image":
+		return drawImageHandler(req)
+	case "/keys":
+		return keysHandler(req)
+	case "/daily":
+		return dailyHandler(req)
+	case "/decks":
+		return decksHandler(req)
+	case "/spreads":
+		return spreadsHandler(req)
+	case "/openapi.json":
+		return openAPIHandler(req)
+	default:
+		return drawHandler(req)
+	}
+}
+
+// requestPath returns the path a request is routed on: the raw path without
+// a trailing slash or, for events that carry none, the path of the route key,
+// such as /cards/{id} in "GET /cards/{id}"
+func requestPath(req events.APIGatewayV2HTTPRequest) string {
+	if req.RawPath != "" {
+		return strings.TrimSuffix(req.RawPath, "/")
+	}
+	_, path, _ := strings.Cut(req.RouteKey, " ")
+	return strings.TrimSuffix(path, "/")
+}
+
+// corsHeaders returns the CORS headers sent with every response
+func corsHeaders() map[string]string {
+	return map[string]string{
 		"Content-Type":                 "application/json",
 		"Access-Control-Allow-Origin":  "https://tarot-react.joshuakite.co.uk",
-		"Access-Control-Allow-Methods": "POST, OPTIONS",
+		"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
 		"Access-Control-Allow-Headers": "content-type, authorization",
 	}
+}
+
+// jsonResponse encodes v as the body of a response with the given status
+func jsonResponse(status int, v any) (events.APIGatewayV2HTTPResponse, error) {
+	body, _ := json.Marshal(v)
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: status,
+		Headers:    corsHeaders(),
+		Body:       string(body),
+	}, nil
+}
+
+// errorResult builds an errorResponse with the given status
+func errorResult(status int, code, message string) (events.APIGatewayV2HTTPResponse, error) {
+	return jsonResponse(status, errorResponse{
+		Error:   code,
+		Message: message,
+	})
+}
+
+// preflight answers a CORS OPTIONS request
+func preflight() (events.APIGatewayV2HTTPResponse, error) {
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    corsHeaders(),
+	}, nil
+}
+
+func drawHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
 
 	// Handle OPTIONS preflight request
 	if req.RequestContext.HTTP.Method == "OPTIONS" {
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: http.StatusOK,
-			Headers:    corsHeaders,
-		}, nil
+		return preflight()
 	}
 
 	// Only allow POST for actual requests
 	if req.RequestContext.HTTP.Method != "POST" {
-		body, _ := json.Marshal(errorResponse{
-			Error:   "method_not_allowed",
-			Message: "Only POST requests are allowed",
-		})
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: http.StatusMethodNotAllowed,
-			Headers:    corsHeaders,
-			Body:       string(body),
-		}, nil
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_post_only"))
 	}
 
-	// Decode JSON request body
+	// Decode the request body and query string
 	var drawReq drawRequest
-	if err := json.Unmarshal([]byte(req.Body), &drawReq); err != nil {
-		body, _ := json.Marshal(errorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid JSON in request body",
-		})
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: http.StatusBadRequest,
-			Headers:    corsHeaders,
-			Body:       string(body),
-		}, nil
+	if err := decodeRequest(req, &drawReq); err != nil {
+		return decodeErrorResult(loc, err)
+	}
+
+	// An explicit locale takes precedence over Accept-Language
+	if drawReq.Locale != "" {
+		loc = newLocalizer(drawReq.Locale)
 	}
 
 	// Validate required fields
 	if drawReq.DeckSize == "" || drawReq.DeckReverse == "" {
-		body, _ := json.Marshal(errorResponse{
-			Error:   "missing_parameters",
-			Message: "deckSize and deckReverse are required",
-		})
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: http.StatusBadRequest,
-			Headers:    corsHeaders,
-			Body:       string(body),
-		}, nil
+		return errorResult(http.StatusBadRequest, "missing_parameters", loc.message("missing_deck_options"))
 	}
 
-	// Default numCards if not provided or invalid
-	if drawReq.NumCards < 1 {
-		drawReq.NumCards = 8
+	// An explicit format takes precedence over Accept; errors are always JSON
+	format, err := negotiateFormat(drawReq.Format, header(req, "Accept"))
+	if err != nil {
+		return errorResult(http.StatusBadRequest, "invalid_format", loc.message("invalid_format"))
 	}
 
-	decks := getDeck(drawReq.DeckSize, drawReq.DeckReverse)
-	if decks == nil {
-		body, _ := json.Marshal(errorResponse{
-			Error:   "invalid_deck_options",
-			Message: "Invalid deck size or reverse option",
-		})
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: http.StatusBadRequest,
-			Headers:    corsHeaders,
-			Body:       string(body),
-		}, nil
+	// A commit-reveal draw combines the committed server seed with the client's
+	// seed; otherwise replay the caller's seed or generate a fresh one
+	var proof *drawProof
+	seed := drawReq.Seed
+	if drawReq.CommitID != "" {
+		if drawReq.Seed != "" {
+			return errorResult(http.StatusBadRequest, "invalid_request", loc.message("seed_with_commit"))
+		}
+		if drawReq.ClientSeed == "" {
+			return errorResult(http.StatusBadRequest, "missing_parameters", loc.message("client_seed_required"))
+		}
+		if !validCommitID(drawReq.CommitID) {
+			return errorResult(http.StatusBadRequest, "invalid_commit", loc.message("invalid_commit"))
+		}
+		proof = revealProof(drawReq.CommitID, drawReq.ClientSeed)
+		seed = combinedSeed(proof.ServerSeed, proof.ClientSeed)
+	} else if seed == "" {
+		seed = newSeed()
 	}
+	src := newSeededSource(seed)
 
-	totalCards := len(decks)
+	dealt, err := dealCards(drawReq.deckOptions, src)
+	if err != nil {
+		return dealErrorResult(loc, err)
+	}
+	drawnCards := dealt.cards
+
+	// A commitment reveals its server seed once; an expired or used commitId
+	// is refused so the reading cannot be redrawn with other client seeds
+	if proof != nil {
+		switch err := claimCommitment(drawReq.CommitID, time.Now()); err {
+		case nil:
+		case errCommitExpired, errCommitUsed:
+			return errorResult(http.StatusBadRequest, "invalid_commit", loc.message("commit_used"))
+		default:
+			return errorResult(http.StatusInternalServerError, "internal_error", loc.message("commit_record_failed"))
+		}
+	}
 
 	message := ""
-	if drawReq.NumCards > totalCards {
-		drawReq.NumCards = totalCards
-		message = "There are no more cards to display."
+	if dealt.clamped {
+		message = loc.message("no_more_cards")
 	}
 
-	shuffledDeck := shuffle(decks)
-	drawnCards := shuffledDeck[:drawReq.NumCards]
-
 	// Update image URLs to use CloudFront
 	for i := range drawnCards {
 		drawnCards[i].Image = cloudFrontURL + "/images/" + drawnCards[i].Image
 	}
 
-	// Send JSON response
-	body, _ := json.Marshal(drawResponse{
-		DrawnCards: drawnCards,
-		Message:    message,
-	})
-	return events.APIGatewayV2HTTPResponse{
-		StatusCode: http.StatusOK,
-		Headers:    corsHeaders,
-		Body:       string(body),
-	}, nil
-}
-
-// Functions for generating the deck, shuffling, etc. remain the same
-
-var majorCards = map[string]string{
-	"I": "The Magician", "II": "The Papess", "III": "The Empress", "IV": "The Emperor",
-	"V": "The Heirophant", "VI": "The Lovers", "VII": "The Chariot", "VIII": "Justice",
-	"IX": "The Hermit", "X": "The Wheel Of Fortune", "XI": "Strength", "XII": "The Hanged Man",
-	"XIII": "Death", "XIV": "Temperance", "XV": "The Devil", "XVI": "The Tower",
-	"XVII": "The Star", "XVIII": "The Moon", "XIX": "The Sun", "XX": "The Last Judgment",
-	"XXI": "The World", "_": "The Fool",
-}
-
-var minorSuits = map[string]string{
-	"Cups": "Cups", "Wands": "Wands", "Swords": "Swords", "Pents": "Pentacles",
-}
+	loc.localizeCards(drawnCards, dealt.tradition.Name)
 
-var minorCards = map[string]string{
-	"01": "Ace", "02": "Two", "03": "Three", "04": "Four", "05": "Five",
-	"06": "Six", "07": "Seven", "08": "Eight", "09": "Nine", "10": "Ten",
-	"11": "Page", "12": "Knight", "13": "Queen", "14": "King",
-}
-
-var majorImages = map[string]string{
-	"I": "RWS_Tarot_01_Magician.jpg", "II": "RWS_Tarot_02_High_Priestess.jpg", "III": "RWS_Tarot_03_Empress.jpg",
-	"IV": "RWS_Tarot_04_Emperor.jpg", "V": "RWS_Tarot_05_Hierophant.jpg", "VI": "RWS_Tarot_06_Lovers.jpg",
-	"VII": "RWS_Tarot_07_Chariot.jpg", "VIII": "RWS_Tarot_08_Strength.jpg", "IX": "RWS_Tarot_09_Hermit.jpg",
-	"X": "RWS_Tarot_10_Wheel_of_Fortune.jpg", "XI": "RWS_Tarot_11_Justice.jpg", "XII": "RWS_Tarot_12_Hanged_Man.jpg",
-	"XIII": "RWS_Tarot_13_Death.jpg", "XIV": "RWS_Tarot_14_Temperance.jpg", "XV": "RWS_Tarot_15_Devil.jpg",
-	"XVI": "RWS_Tarot_16_Tower.jpg", "XVII": "RWS_Tarot_17_Star.jpg", "XVIII": "RWS_Tarot_18_Moon.jpg",
-	"XIX": "RWS_Tarot_19_Sun.jpg", "XX": "RWS_Tarot_20_Judgement.jpg", "XXI": "RWS_Tarot_21_World.jpg",
-	"_": "RWS_Tarot_00_Fool.jpg",
-}
-
-var minorImages = map[string]string{
-	"Cups01": "Cups01.jpg", "Cups02": "Cups02.jpg", "Cups03": "Cups03.jpg",
-	"Cups04": "Cups04.jpg", "Cups05": "Cups05.jpg", "Cups06": "Cups06.jpg",
-	"Cups07": "Cups07.jpg", "Cups08": "Cups08.jpg", "Cups09": "Cups09.jpg",
-	"Cups10": "Cups10.jpg", "Cups11": "Cups11.jpg", "Cups12": "Cups12.jpg",
-	"Cups13": "Cups13.jpg", "Cups14": "Cups14.jpg", "Pents01": "Pents01.jpg",
-	"Pents02": "Pents02.jpg", "Pents03": "Pents03.jpg", "Pents04": "Pents04.jpg",
-	"Pents05": "Pents05.jpg", "Pents06": "Pents06.jpg", "Pents07": "Pents07.jpg",
-	"Pents08": "Pents08.jpg", "Pents09": "Pents09.jpg", "Pents10": "Pents10.jpg",
-	"Pents11": "Pents11.jpg", "Pents12": "Pents12.jpg", "Pents13": "Pents13.jpg",
-	"Pents14": "Pents14.jpg", "Swords01": "Swords01.jpg", "Swords02": "Swords02.jpg",
-	"Swords03": "Swords03.jpg", "Swords04": "Swords04.jpg", "Swords05": "Swords05.jpg",
-	"Swords06": "Swords06.jpg", "Swords07": "Swords07.jpg", "Swords08": "Swords08.jpg",
-	"Swords09": "Swords09.jpg", "Swords10": "Swords10.jpg", "Swords11": "Swords11.jpg",
-	"Swords12": "Swords12.jpg", "Swords13": "Swords13.jpg", "Swords14": "Swords14.jpg",
-	"Wands01": "Wands01.jpg", "Wands02": "Wands02.jpg", "Wands03": "Wands03.jpg",
-	"Wands04": "Wands04.jpg", "Wands05": "Wands05.jpg", "Wands06": "Wands06.jpg",
-	"Wands07": "Wands07.jpg", "Wands08": "Wands08.jpg", "Wands09": "Tarot_Nine_of_Wands.jpg",
-	"Wands10": "Wands10.jpg", "Wands11": "Wands11.jpg", "Wands12": "Wands12.jpg",
-	"Wands13": "Wands13.jpg", "Wands14": "Wands14.jpg",
-}
-
-// getDeck function generates the deck based on input
-func getDeck(deckSize, deckReverse string) []tarotDeck {
-	var decks []tarotDeck
-	switch deckSize {
-	case "Major Arcana only":
-		decks = majorArcana()
-	case "Minor Arcana only":
-		decks = minorArcana()
-	case "Full Deck":
-		decks = append(majorArcana(), minorArcana()...)
-	default:
-		// If deckSize is invalid, return nil
-		return nil
+	if drawReq.IncludeMeanings {
+		attachMeanings(drawnCards)
 	}
 
-	if deckReverse == "Upright and reversed" {
-		decks = includeReversed(decks)
+	// Sign a receipt so the reading can later be proven to come from us
+	receipt, err := signReceipt(signingKey, drawnCards, newReceiptOptions(drawReq.deckOptions, dealt, loc.locale), seed, time.Now())
+	if err != nil {
+		return errorResult(http.StatusInternalServerError, "internal_error", loc.message("receipt_failed"))
 	}
 
-	return decks
-}
-
-// majorArcana generates the major arcana deck
-func majorArcana() []tarotDeck {
-	var majorArcana []tarotDeck
-	for key, value := range majorCards {
-		majorArcana = append(majorArcana, tarotDeck{
-			Number:   key,
-			NameSuit: value,
-			Image:    majorImages[key]})
-	}
-	return majorArcana
-}
-
-// minorArcana generates the minor arcana deck
-func minorArcana() []tarotDeck {
-	var minorArcana []tarotDeck
-	for suit, fullSuitName := range minorSuits {
-		for number, fullNumberName := range minorCards {
-			key := suit + number
-			minorArcana = append(minorArcana, tarotDeck{
-				Number:   fullNumberName,
-				NameSuit: "of " + fullSuitName,
-				Image:    minorImages[key]})
+	// Seal the rest of the deck so clarifiers can be drawn from it later.
+	// Draws with replacement have no deck left to draw from.
+	deckState := ""
+	if !drawReq.Replacement {
+		if deckState, err = newDeckState(drawReq.deckOptions, dealt).seal(); err != nil {
+			return errorResult(http.StatusInternalServerError, "internal_error", loc.message("deck_state_failed"))
 		}
 	}
-	return minorArcana
+
+	// Send the reading as JSON or in the negotiated format
+	return readingResponse(format, drawResponse{
+		DrawnCards: drawnCards,
+		Message:    message,
+		Seed:       seed,
+		Locale:     loc.locale,
+		Tradition:  dealt.tradition.Name,
+		Spread:     dealt.spreadID(),
+		Shuffle:    dealt.shuffle,
+		DeckState:  deckState,
+		Proof:      proof,
+		Receipt:    receipt,
+	}, loc)
 }
 
+// Functions for generating the deck, shuffling, etc. remain the same
+
 // includeReversed includes reversed cards in the deck
-func includeReversed(decks []tarotDeck) []tarotDeck {
-	var newDecks []tarotDeck
-	for i := range decks {
-		b := make([]byte, 1)
-		rand.Read(b)
-		if b[0]%2 == 0 {
-			newDecks = append(newDecks, tarotDeck{
-				Number:   decks[i].Number,
-				NameSuit: decks[i].NameSuit,
-				Image:    decks[i].Image,
-			})
-		} else {
-			newDecks = append(newDecks, tarotDeck{
-				Number:   decks[i].Number,
-				NameSuit: decks[i].NameSuit,
-				Reversed: "(Reversed)",
-				Image:    decks[i].Image,
-			})
-		}
+func includeReversed(decks []Card, src RandomSource) []Card {
+	var newDecks []Card
+	for _, card := range decks {
+		card.Reversed = uniformInt(src, 2) == 1
+		newDecks = append(newDecks, card)
 	}
 	return newDecks
 }
 
-// shuffle shuffles the deck
-func shuffle(decks []tarotDeck) []tarotDeck {
-	for i := range decks {
-		b := make([]byte, 1)
-		rand.Read(b)
-		j := int(b[0]) % (i + 1)
+// shuffle shuffles the deck in place using a Fisher-Yates shuffle
+func shuffle(decks []Card, src RandomSource) []Card {
+	for i := len(decks) - 1; i > 0; i-- {
+		j := uniformInt(src, i+1)
 		decks[i], decks[j] = decks[j], decks[i]
 	}
 	return decks
@@ -2,7 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -325,9 +332,9 @@ func TestDrawHandler_TooManyCards(t *testing.T) {
 }
 
 func TestGetDeck_MajorArcana(t *testing.T) {
-	deck := getDeck("Major Arcana only", "Upright only")
-	if deck == nil {
-		t.Fatal("Expected deck, got nil")
+	deck, _, err := getDeck("Major Arcana only", "Upright only", "", nil, nil, defaultSource)
+	if err != nil {
+		t.Fatalf("Expected deck, got %v", err)
 	}
 	if len(deck) != 22 {
 		t.Errorf("Expected 22 major arcana cards, got %d", len(deck))
@@ -335,9 +342,9 @@ func TestGetDeck_MajorArcana(t *testing.T) {
 }
 
 func TestGetDeck_MinorArcana(t *testing.T) {
-	deck := getDeck("Minor Arcana only", "Upright only")
-	if deck == nil {
-		t.Fatal("Expected deck, got nil")
+	deck, _, err := getDeck("Minor Arcana only", "Upright only", "", nil, nil, defaultSource)
+	if err != nil {
+		t.Fatalf("Expected deck, got %v", err)
 	}
 	if len(deck) != 56 {
 		t.Errorf("Expected 56 minor arcana cards, got %d", len(deck))
@@ -345,9 +352,9 @@ func TestGetDeck_MinorArcana(t *testing.T) {
 }
 
 func TestGetDeck_FullDeck(t *testing.T) {
-	deck := getDeck("Full Deck", "Upright only")
-	if deck == nil {
-		t.Fatal("Expected deck, got nil")
+	deck, _, err := getDeck("Full Deck", "Upright only", "", nil, nil, defaultSource)
+	if err != nil {
+		t.Fatalf("Expected deck, got %v", err)
 	}
 	if len(deck) != 78 {
 		t.Errorf("Expected 78 cards in full deck, got %d", len(deck))
@@ -355,18 +362,18 @@ func TestGetDeck_FullDeck(t *testing.T) {
 }
 
 func TestGetDeck_InvalidDeckSize(t *testing.T) {
-	deck := getDeck("Invalid Size", "Upright only")
-	if deck != nil {
-		t.Error("Expected nil for invalid deck size")
+	deck, _, err := getDeck("Invalid Size", "Upright only", "", nil, nil, defaultSource)
+	if deck != nil || err != errUnknownDeck {
+		t.Errorf("Expected errUnknownDeck for invalid deck size, got %v", err)
 	}
 }
 
 func TestShuffle(t *testing.T) {
-	deck := getDeck("Major Arcana only", "Upright only")
-	original := make([]tarotDeck, len(deck))
+	deck, _, _ := getDeck("Major Arcana only", "Upright only", "", nil, nil, defaultSource)
+	original := make([]Card, len(deck))
 	copy(original, deck)
 
-	shuffled := shuffle(deck)
+	shuffled := shuffle(deck, defaultSource)
 
 	if len(shuffled) != len(original) {
 		t.Errorf("Shuffle changed deck size: expected %d, got %d", len(original), len(shuffled))
@@ -385,3 +392,368 @@ func TestShuffle(t *testing.T) {
 		t.Error("Shuffle did not change card order")
 	}
 }
+
+func TestDrawHandler_SeedReproducesDraw(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	body := `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 10, "seed": "qa-reading-42"}`
+	var first drawResponse
+	call(t, apiRequest("POST", "/draw", body, nil), 200, &first)
+	var second drawResponse
+	call(t, apiRequest("POST", "/draw", body, nil), 200, &second)
+
+	if first.Seed != "qa-reading-42" {
+		t.Errorf("Expected seed 'qa-reading-42' to be echoed, got '%s'", first.Seed)
+	}
+
+	for i := range first.DrawnCards {
+		if first.DrawnCards[i] != second.DrawnCards[i] {
+			t.Errorf("Card %d differs between seeded draws: %+v vs %+v", i, first.DrawnCards[i], second.DrawnCards[i])
+		}
+	}
+}
+
+func TestDrawHandler_UnseededReturnsSeed(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var first drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 10}`, nil), 200, &first)
+	if first.Seed == "" {
+		t.Fatal("Expected generated seed in response")
+	}
+
+	// Replaying the returned seed must give the same reading
+	var replay drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 10, "seed": "`+first.Seed+`"}`, nil), 200, &replay)
+	for i := range first.DrawnCards {
+		if first.DrawnCards[i] != replay.DrawnCards[i] {
+			t.Errorf("Card %d differs on replay: %+v vs %+v", i, first.DrawnCards[i], replay.DrawnCards[i])
+		}
+	}
+
+	var second drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 10}`, nil), 200, &second)
+	if second.Seed == first.Seed {
+		t.Error("Expected unseeded draws to use different seeds")
+	}
+}
+
+// openAPISpec parses the embedded OpenAPI document
+func openAPISpec(t *testing.T) map[string]any {
+	t.Helper()
+	var spec map[string]any
+	if err := json.Unmarshal(openAPIJSON, &spec); err != nil {
+		t.Fatalf("Failed to parse data/openapi.json: %v", err)
+	}
+	return spec
+}
+
+// resolveRef follows a local reference such as #/components/schemas/Card,
+// unescaping "/" written as ~1 in the names of paths
+func resolveRef(spec map[string]any, ref string) (map[string]any, error) {
+	var node any = spec
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+		node = object[strings.NewReplacer("~1", "/", "~0", "~").Replace(part)]
+	}
+	resolved, ok := node.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %s", ref)
+	}
+	return resolved, nil
+}
+
+// validateSchema checks value, decoded from JSON, against the subset of JSON
+// Schema the OpenAPI document and the deck schema use: $ref, allOf, oneOf,
+// type, enum, pattern, minLength, minItems, minProperties, properties,
+// required, additionalProperties, propertyNames, items, minimum and maximum
+func validateSchema(spec, schema map[string]any, value any, at string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := resolveRef(spec, ref)
+		if err != nil {
+			return err
+		}
+		return validateSchema(spec, resolved, value, at)
+	}
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			if err := validateSchema(spec, sub.(map[string]any), value, at); err != nil {
+				return err
+			}
+		}
+	}
+	if one, ok := schema["oneOf"].([]any); ok {
+		matches := 0
+		for _, sub := range one {
+			if validateSchema(spec, sub.(map[string]any), value, at) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d oneOf schemas", at, matches)
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		return fmt.Errorf("%s: %v is not one of %v", at, value, enum)
+	}
+	if minimum, ok := schema["minimum"].(float64); ok {
+		if n, ok := value.(float64); ok && n < minimum {
+			return fmt.Errorf("%s: %v is below %v", at, n, minimum)
+		}
+	}
+	if maximum, ok := schema["maximum"].(float64); ok {
+		if n, ok := value.(float64); ok && n > maximum {
+			return fmt.Errorf("%s: %v is above %v", at, n, maximum)
+		}
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		if s, ok := value.(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			return fmt.Errorf("%s: %q does not match %s", at, s, pattern)
+		}
+	}
+	if minLength, ok := schema["minLength"].(float64); ok {
+		if s, ok := value.(string); ok && float64(len([]rune(s))) < minLength {
+			return fmt.Errorf("%s: %q is shorter than %v", at, s, minLength)
+		}
+	}
+
+	switch schema["type"] {
+	case nil:
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected a string, got %T", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", at, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected a number, got %T", at, value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected an integer, got %v", at, value)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", at, value)
+		}
+		if minItems, ok := schema["minItems"].(float64); ok && float64(len(items)) < minItems {
+			return fmt.Errorf("%s: expected at least %v items, got %d", at, minItems, len(items))
+		}
+		if itemSchema, ok := schema["items"].(map[string]any); ok {
+			for i, item := range items {
+				if err := validateSchema(spec, itemSchema, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", at, value)
+		}
+		if minProperties, ok := schema["minProperties"].(float64); ok && float64(len(object)) < minProperties {
+			return fmt.Errorf("%s: expected at least %v properties, got %d", at, minProperties, len(object))
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required property %s", at, name)
+			}
+		}
+		for name, field := range object {
+			if names, ok := schema["propertyNames"].(map[string]any); ok {
+				if err := validateSchema(spec, names, name, at+"."+name); err != nil {
+					return err
+				}
+			}
+			propSchema, ok := properties[name].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s: undocumented property %s", at, name)
+				}
+				if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+					if err := validateSchema(spec, additional, field, at+"."+name); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := validateSchema(spec, propSchema, field, at+"."+name); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported type %v", at, schema["type"])
+	}
+	return nil
+}
+
+// checkContract checks that resp is a response the document lists for the
+// operation at route, e.g. "GET /cards/{id}", and that its body matches the
+// schema given for its status and Content-Type
+func checkContract(t *testing.T, spec map[string]any, route string, resp events.APIGatewayV2HTTPResponse) {
+	t.Helper()
+	method, path, _ := strings.Cut(route, " ")
+	operation, err := resolveRef(spec, "#/paths/"+strings.ReplaceAll(path, "/", "~1")+"/"+strings.ToLower(method))
+	if err != nil {
+		t.Fatalf("%s: expected the operation in the document, got %v", route, err)
+	}
+	response, ok := operation["responses"].(map[string]any)[strconv.Itoa(resp.StatusCode)].(map[string]any)
+	if !ok {
+		t.Errorf("%s: status %d is not documented: %s", route, resp.StatusCode, resp.Body)
+		return
+	}
+	if ref, ok := response["$ref"].(string); ok {
+		if response, err = resolveRef(spec, ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Headers["Content-Type"])
+	content, ok := response["content"].(map[string]any)[mediaType].(map[string]any)
+	if !ok {
+		t.Errorf("%s: Content-Type %q is not documented for status %d", route, resp.Headers["Content-Type"], resp.StatusCode)
+		return
+	}
+
+	// JSON bodies are checked against their schema, others as strings
+	var body any = resp.Body
+	if mediaType == "application/json" {
+		if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+			t.Fatalf("%s: failed to parse response: %v", route, err)
+		}
+	}
+	if err := validateSchema(spec, content["schema"].(map[string]any), body, "body"); err != nil {
+		t.Errorf("%s %d: %v", route, resp.StatusCode, err)
+	}
+}
+
+func TestOpenAPI_Contract(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+	spec := openAPISpec(t)
+
+	called := map[string]bool{}
+	// callRoute sends a request with the method of route, or with method when
+	// one is given, and checks the response against route's operation
+	callRoute := func(route, rawPath, body string, query map[string]string, method ...string) map[string]any {
+		t.Helper()
+		routeMethod, _, _ := strings.Cut(route, " ")
+		resp, err := handleRequest(apiRequest(append(method, routeMethod)[0], rawPath, body, query))
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", route, err)
+		}
+		checkContract(t, spec, route, resp)
+		called[route] = true
+
+		var decoded map[string]any
+		json.Unmarshal([]byte(resp.Body), &decoded)
+		return decoded
+	}
+
+	full := `"deckSize": "Full Deck", "deckReverse": "Upright and reversed"`
+	callRoute("POST /draw", "/draw", `{`+full+`, "numCards": 5, "includeMeanings": true, "shuffle": {"strategy": "riffle", "cut": true}}`, nil)
+	callRoute("POST /draw", "/draw", `{`+full+`, "spread": "celtic-cross", "significator": "cups-13", "copies": 2}`, nil)
+	callRoute("POST /draw", "/draw", `{`+full+`, "numCards": 100}`, nil)
+	callRoute("POST /draw", "/draw", `{"deckSize": "52-card deck", "deckReverse": "Upright only", "replacement": true, "numCards": 60}`, nil)
+	callRoute("POST /draw", "/draw", `{"numCards": 5}`, nil)
+	callRoute("POST /draw", "/draw", `{`+full+`, "filter": {"only": "courts"}, "numCards": 20}`, nil)
+	callRoute("POST /draw", "/draw", `{`+full+`, "format": "yaml"}`, nil)
+	for _, format := range []string{"text", "markdown", "html", "csv"} {
+		callRoute("POST /draw", "/draw", `{`+full+`, "spread": "three-card", "format": "`+format+`"}`, nil)
+	}
+	callRoute("POST /draw", "/draw", "", nil, "GET")
+
+	commit := callRoute("POST /draw/commit", "/draw/commit", "", nil)
+	drawn := callRoute("POST /draw", "/draw", `{`+full+`, "numCards": 3, "commitId": "`+commit["commitId"].(string)+`", "clientSeed": "contract"}`, nil)
+	proof := drawn["proof"].(map[string]any)
+	callRoute("POST /draw/verify", "/draw/verify", `{`+full+`, "numCards": 3, "commitment": "`+proof["commitment"].(string)+`", "serverSeed": "`+proof["serverSeed"].(string)+`", "clientSeed": "contract"}`, nil)
+	callRoute("POST /draw/verify", "/draw/verify", `{`+full+`, "numCards": 3, "commitment": "00", "serverSeed": "`+proof["serverSeed"].(string)+`", "clientSeed": "contract"}`, nil)
+	callRoute("POST /draw/verify", "/draw/verify", `{`+full+`}`, nil)
+
+	deckState := drawn["deckState"].(string)
+	callRoute("POST /draw/clarify", "/draw/clarify", `{"deckState": "`+deckState+`", "position": 2, "includeMeanings": true}`, nil)
+	callRoute("POST /draw/clarify", "/draw/clarify", `{"deckState": "`+deckState+`", "position": 9}`, nil)
+	callRoute("POST /draw/clarify", "/draw/clarify", `{"deckState": "ds1.tampered", "position": 1}`, nil)
+	last := callRoute("POST /draw", "/draw", `{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "numCards": 22}`, nil)
+	callRoute("POST /draw/clarify", "/draw/clarify", `{"deckState": "`+last["deckState"].(string)+`", "position": 1}`, nil)
+
+	useTestCardImages(t)
+	callRoute("POST /draw/image", "/draw/image", `{`+full+`, "spread": "celtic-cross", "cardWidth": 60}`, nil)
+	callRoute("POST /draw/image", "/draw/image", `{`+full+`, "numCards": 3, "format": "jpeg"}`, nil)
+	callRoute("POST /draw/image", "/draw/image", `{"deckSize": "lenormand", "deckReverse": "Upright only"}`, nil)
+	callRoute("POST /draw/image", "/draw/image", "", nil, "DELETE")
+	callRoute("GET /draw/image", "/draw/image", "", map[string]string{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "spread": "three-card", "cardWidth": "60"})
+	callRoute("GET /draw/image", "/draw/image", "", map[string]string{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "numCards": "30"})
+
+	callRoute("GET /keys", "/keys", "", nil)
+	callRoute("GET /daily", "/daily", "", map[string]string{"user": "contract", "tz": "Europe/Paris", "includeMeanings": "true"})
+	callRoute("GET /daily", "/daily", "", map[string]string{"user": "contract", "tz": "Nowhere/Special"})
+
+	session := callRoute("POST /sessions", "/sessions", `{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "significator": "major-00-fool", "shuffle": {"strategy": "piles"}}`, nil)
+	id := session["sessionId"].(string)
+	callRoute("POST /sessions", "/sessions", `{"deckSize": "Major Arcana only"}`, nil)
+	callRoute("POST /sessions/{id}/draw", "/sessions/"+id+"/draw", `{"numCards": 30, "includeMeanings": true}`, nil)
+	callRoute("POST /sessions/{id}/draw", "/sessions/"+id+"/draw", "", nil)
+	callRoute("GET /sessions/{id}", "/sessions/"+id, "", nil)
+	callRoute("GET /sessions/{id}", "/sessions/"+strings.Repeat("0", 32), "", nil)
+
+	callRoute("GET /cards", "/cards", "", map[string]string{"includeMeanings": "true"})
+	callRoute("GET /cards", "/cards", "", map[string]string{"deck": "lenormand"})
+	callRoute("GET /cards", "/cards", "", map[string]string{"deck": "Tiny Deck"})
+	callRoute("GET /cards/{id}", "/cards/swords-12", "", map[string]string{"tradition": "thoth"})
+	callRoute("GET /cards/{id}", "/cards/swords-15", "", nil)
+	callRoute("GET /decks", "/decks", "", nil)
+	callRoute("GET /spreads", "/spreads", "", nil)
+	callRoute("GET /openapi.json", "/openapi.json", "", nil)
+	callRoute("GET /openapi.json", "/openapi.json", "", nil, "POST")
+
+	// Every operation in the document is exercised above
+	for path, item := range spec["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			if route := strings.ToUpper(method) + " " + path; method != "parameters" && !called[route] {
+				t.Errorf("Expected a contract check for %s", route)
+			}
+		}
+	}
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	resp, err := handleRequest(apiRequest("GET", "/openapi.json", "", nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var served map[string]any
+	if err := json.Unmarshal([]byte(resp.Body), &served); err != nil {
+		t.Fatalf("Failed to parse the served document: %v", err)
+	}
+	if resp.StatusCode != 200 || served["openapi"] != "3.1.0" {
+		t.Fatalf("Expected an OpenAPI 3.1.0 document, got %d %v", resp.StatusCode, served["openapi"])
+	}
+
+	// Each API Gateway route is described in the document
+	terraform, err := os.ReadFile("../terraform/api_gateway.tf")
+	if err != nil {
+		t.Fatalf("Failed to read api_gateway.tf: %v", err)
+	}
+	routes := regexp.MustCompile(`route_key\s*=\s*"([A-Z]+) ([^"]+)"`).FindAllStringSubmatch(string(terraform), -1)
+	if len(routes) == 0 {
+		t.Fatal("Expected routes in api_gateway.tf")
+	}
+	paths := served["paths"].(map[string]any)
+	for _, route := range routes {
+		item, ok := paths[route[2]].(map[string]any)
+		if !ok || item[strings.ToLower(route[1])] == nil {
+			t.Errorf("Expected %s %s in the document", route[1], route[2])
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+// cardMeaning is the interpretation attached to a drawn card for its orientation
+type cardMeaning struct {
+	Orientation string   `json:"orientation"`
+	Meaning     string   `json:"meaning"`
+	Keywords    []string `json:"keywords"`
+}
+
+type orientedMeaning struct {
+	Meaning  string   `json:"meaning"`
+	Keywords []string `json:"keywords"`
+}
+
+type cardMeanings struct {
+	Upright  orientedMeaning `json:"upright"`
+	Reversed orientedMeaning `json:"reversed"`
+}
+
+//go:embed data/meanings.json
+var meaningsJSON []byte
+
+// meanings holds the upright and reversed meanings of every card, keyed by card ID
+var meanings = loadMeanings()
+
+func loadMeanings() map[string]cardMeanings {
+	var m map[string]cardMeanings
+	if err := json.Unmarshal(meaningsJSON, &m); err != nil {
+		panic("invalid data/meanings.json: " + err.Error())
+	}
+	return m
+}
+
+// meaningFor returns the meaning of card in its drawn orientation, or nil if
+// the card has no entry in the dataset
+func meaningFor(card Card) *cardMeaning {
+	m, ok := meanings[card.ID]
+	if !ok {
+		return nil
+	}
+	if card.Reversed {
+		return &cardMeaning{Orientation: "reversed", Meaning: m.Reversed.Meaning, Keywords: m.Reversed.Keywords}
+	}
+	return &cardMeaning{Orientation: "upright", Meaning: m.Upright.Meaning, Keywords: m.Upright.Keywords}
+}
+
+// attachMeanings sets the meaning of each card according to its orientation
+func attachMeanings(cards []Card) {
+	for i := range cards {
+		cards[i].Meaning = meaningFor(cards[i])
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMeanings_CoverEveryCard(t *testing.T) {
+	for _, card := range append(tarotDeck("tarot-major", "rws"), tarotDeck("tarot-minor", "rws")...) {
+		m, ok := meanings[card.ID]
+		if !ok {
+			t.Errorf("No meanings for %s", card.ID)
+			continue
+		}
+		if m.Upright.Meaning == "" || len(m.Upright.Keywords) == 0 {
+			t.Errorf("Missing upright meaning or keywords for %s", card.ID)
+		}
+		if m.Reversed.Meaning == "" || len(m.Reversed.Keywords) == 0 {
+			t.Errorf("Missing reversed meaning or keywords for %s", card.ID)
+		}
+	}
+
+	if len(meanings) != 78 {
+		t.Errorf("Expected meanings for exactly 78 cards, got %d", len(meanings))
+	}
+}
+
+func TestDrawHandler_IncludeMeanings(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 20, "seed": "meanings", "includeMeanings": true}`, nil), 200, &drawResp)
+
+	for _, card := range drawResp.DrawnCards {
+		if card.Meaning == nil {
+			t.Errorf("Expected meaning for %s", card.ID)
+			continue
+		}
+
+		want := meanings[card.ID].Upright
+		if card.Reversed {
+			want = meanings[card.ID].Reversed
+		}
+		if card.Meaning.Meaning != want.Meaning {
+			t.Errorf("%s: expected meaning for its orientation, got '%s'", card.ID, card.Meaning.Meaning)
+		}
+		if (card.Meaning.Orientation == "reversed") != card.Reversed {
+			t.Errorf("%s: orientation '%s' does not match card", card.ID, card.Meaning.Orientation)
+		}
+	}
+}
+
+func TestDrawHandler_MeaningsOmittedByDefault(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 5}`, nil), 200, &drawResp)
+	for _, card := range drawResp.DrawnCards {
+		if card.Meaning != nil {
+			t.Errorf("Expected no meaning for %s unless requested", card.ID)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// openAPIJSON is the OpenAPI 3.1 description of every route the function
+// serves. The contract tests in main_test.go check handler responses
+// against it, so it has to change along with them.
+//
+//go:embed data/openapi.json
+var openAPIJSON []byte
+
+// openAPIDocument holds openAPIJSON compacted, as it is served
+var openAPIDocument = loadOpenAPIDocument()
+
+func loadOpenAPIDocument() string {
+	var doc map[string]any
+	if err := json.Unmarshal(openAPIJSON, &doc); err != nil {
+		panic("invalid data/openapi.json: " + err.Error())
+	}
+	compact, _ := json.Marshal(doc)
+	return string(compact)
+}
+
+// openAPIHandler serves GET /openapi.json
+func openAPIHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "GET" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_get_only"))
+	}
+
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    corsHeaders(),
+		Body:       openAPIDocument,
+	}, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	mrand "math/rand/v2"
+)
+
+// RandomSource supplies the raw randomness used to shuffle and reverse cards.
+type RandomSource interface {
+	// Uint64 returns a uniformly distributed 64-bit value.
+	Uint64() uint64
+}
+
+// cryptoSource is a RandomSource backed by crypto/rand
+type cryptoSource struct{}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// defaultSource reads directly from crypto/rand where no replayable seed is needed
+var defaultSource RandomSource = cryptoSource{}
+
+// newSeed generates a fresh random seed for draws that do not supply one
+func newSeed() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// newSeededSource returns a deterministic RandomSource keyed by seed.
+// ChaCha8 is a cryptographically strong generator, so a draw seeded from
+// newSeed is as unpredictable as one read directly from crypto/rand, while
+// replaying the same seed reproduces the same sequence.
+func newSeededSource(seed string) RandomSource {
+	return mrand.NewChaCha8(sha256.Sum256([]byte(seed)))
+}
+
+// uniformInt returns an unbiased integer in [0, n) drawn from src.
+// Values from the short tail of the 64-bit range that would favour low
+// results are rejected and redrawn, so every outcome is equally likely.
+func uniformInt(src RandomSource, n int) int {
+	if n <= 0 {
+		panic("uniformInt: n must be positive")
+	}
+	bound := uint64(n)
+	threshold := -bound % bound
+	for {
+		v := src.Uint64()
+		if v >= threshold {
+			return int(v % bound)
+		}
+	}
+}
+
+// uniformFloat returns a float64 in [0, 1) drawn from src, using the top 53
+// bits so that every value is exactly representable
+func uniformFloat(src RandomSource) float64 {
+	return float64(src.Uint64()>>11) / (1 << 53)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"math"
+	mrand "math/rand/v2"
+	"testing"
+)
+
+// pcgSource is a deterministic RandomSource so the statistical tests are repeatable
+type pcgSource struct {
+	r *mrand.PCG
+}
+
+func newPCGSource(seed uint64) *pcgSource {
+	return &pcgSource{r: mrand.NewPCG(seed, seed^0x9e3779b97f4a7c15)}
+}
+
+func (s *pcgSource) Uint64() uint64 {
+	return s.r.Uint64()
+}
+
+// fixedSource replays a fixed sequence of values, then repeats the last one
+type fixedSource struct {
+	values []uint64
+	calls  int
+}
+
+func (s *fixedSource) Uint64() uint64 {
+	i := s.calls
+	if i >= len(s.values) {
+		i = len(s.values) - 1
+	}
+	s.calls++
+	return s.values[i]
+}
+
+// chiSquareCritical approximates the upper critical value of the chi-square
+// distribution for df degrees of freedom at p = 0.001 (Wilson-Hilferty).
+func chiSquareCritical(df int) float64 {
+	const z = 3.090
+	k := float64(df)
+	h := 2 / (9 * k)
+	return k * math.Pow(1-h+z*math.Sqrt(h), 3)
+}
+
+func TestUniformInt_Range(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 22, 56, 78, 1000} {
+		for i := 0; i < 200; i++ {
+			v := uniformInt(defaultSource, n)
+			if v < 0 || v >= n {
+				t.Fatalf("uniformInt(%d) returned %d", n, v)
+			}
+		}
+	}
+}
+
+func TestUniformInt_RejectsBiasedTail(t *testing.T) {
+	// For n = 3 the threshold is 2^64 mod 3 = 1, so 0 must be rejected
+	src := &fixedSource{values: []uint64{0, 4}}
+	if v := uniformInt(src, 3); v != 1 {
+		t.Errorf("Expected 1 after rejecting biased value, got %d", v)
+	}
+	if src.calls != 2 {
+		t.Errorf("Expected 2 draws from source, got %d", src.calls)
+	}
+}
+
+func TestUniformInt_PanicsOnNonPositive(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for n = 0")
+		}
+	}()
+	uniformInt(defaultSource, 0)
+}
+
+// positionChiSquare shuffles a size-card deck trials times and returns the
+// chi-square statistic of how often each card lands in each position, and
+// its degrees of freedom
+func positionChiSquare(size, trials int, shuffled func([]Card) []Card) (float64, int) {
+	// counts[card][position]
+	counts := make([][]int, size)
+	for i := range counts {
+		counts[i] = make([]int, size)
+	}
+
+	deck := make([]Card, size)
+	for trial := 0; trial < trials; trial++ {
+		for i := range deck {
+			deck[i] = Card{Number: string(rune(i))}
+		}
+		for pos, card := range shuffled(deck) {
+			counts[int([]rune(card.Number)[0])][pos]++
+		}
+	}
+
+	expected := float64(trials) / float64(size)
+	chi := 0.0
+	for card := range counts {
+		for pos := range counts[card] {
+			d := float64(counts[card][pos]) - expected
+			chi += d * d / expected
+		}
+	}
+	return chi, (size - 1) * (size - 1)
+}
+
+func TestShuffle_PositionUniformity(t *testing.T) {
+	for _, size := range []int{22, 56, 78} {
+		src := newPCGSource(uint64(size))
+		chi, df := positionChiSquare(size, 100*size, func(deck []Card) []Card { return shuffle(deck, src) })
+		if critical := chiSquareCritical(df); chi > critical {
+			t.Errorf("%d-card deck: chi-square %.1f exceeds critical value %.1f (df=%d)", size, chi, critical, df)
+		}
+	}
+}
+
+func TestIncludeReversed_Balanced(t *testing.T) {
+	src := newPCGSource(7)
+	deck, _, _ := getDeck("Full Deck", "Upright only", "", nil, nil, defaultSource)
+
+	reversed, total := 0, 0
+	for i := 0; i < 200; i++ {
+		for _, card := range includeReversed(deck, src) {
+			if card.Reversed {
+				reversed++
+			}
+			total++
+		}
+	}
+
+	// Two outcomes, df = 1
+	expected := float64(total) / 2
+	d := float64(reversed) - expected
+	chi := 2 * d * d / expected
+	if critical := chiSquareCritical(1); chi > critical {
+		t.Errorf("Reversal rate %d/%d is not balanced (chi-square %.2f)", reversed, total, chi)
+	}
+}
+
+func TestUniformFloat_Range(t *testing.T) {
+	if v := uniformFloat(&fixedSource{values: []uint64{math.MaxUint64}}); v >= 1 {
+		t.Errorf("Expected a value below 1, got %v", v)
+	}
+	if v := uniformFloat(&fixedSource{values: []uint64{0}}); v != 0 {
+		t.Errorf("Expected 0, got %v", v)
+	}
+}
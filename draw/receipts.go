@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Signed receipts let partners prove a reading came from this Lambda.
+//
+// Each draw response carries a receipt with a detached Ed25519 signature over
+// the canonical JSON of
+//
+//	{"drawnCards": [...], "options": {...}, "seed": "...", "timestamp": "..."}
+//
+// where canonical means object keys sorted, no insignificant whitespace and no
+// HTML escaping. The public key is published at GET /keys.
+//
+// The private key is loaded at cold start from RECEIPT_SIGNING_KEY, or from the
+// file named by RECEIPT_SIGNING_KEY_FILE. Either may hold a PEM encoded PKCS #8
+// key (as written by `openssl genpkey -algorithm ed25519`) or a base64 encoded
+// 32-byte seed or 64-byte private key. Without either, an ephemeral key is
+// generated and receipts only verify against that container's published key.
+
+// receiptOptions are every deck option the reading was dealt with, with the
+// number of cards, tradition and spread filled in as dealt, and the locale
+// its cards are named in
+type receiptOptions struct {
+	deckOptions
+	Locale string `json:"locale"`
+}
+
+// newReceiptOptions returns the options to sign for a reading dealt with opts
+func newReceiptOptions(opts deckOptions, dealt *dealing, locale string) receiptOptions {
+	opts.NumCards = dealt.numCards
+	opts.Tradition = dealt.tradition.Name
+	// A custom spread is signed as sent, so the options deal the reading again
+	if opts.CustomSpread == nil {
+		opts.Spread = dealt.spreadID()
+	}
+	return receiptOptions{deckOptions: opts, Locale: locale}
+}
+
+type drawReceipt struct {
+	KeyID     string         `json:"keyId"`
+	Algorithm string         `json:"algorithm"`
+	Timestamp string         `json:"timestamp"`
+	Options   receiptOptions `json:"options"`
+	Signature string         `json:"signature"`
+}
+
+type receiptPayload struct {
+	DrawnCards []Card         `json:"drawnCards"`
+	Options    receiptOptions `json:"options"`
+	Seed       string         `json:"seed"`
+	Timestamp  string         `json:"timestamp"`
+}
+
+type publicKey struct {
+	KeyID     string `json:"keyId"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+}
+
+type keysResponse struct {
+	Keys []publicKey `json:"keys"`
+}
+
+var errInvalidSigningKey = errors.New("signing key must be a PEM PKCS #8 Ed25519 key or a base64 seed or private key")
+
+// signingKey signs every draw receipt
+var signingKey = loadSigningKey()
+
+func loadSigningKey() ed25519.PrivateKey {
+	data := []byte(os.Getenv("RECEIPT_SIGNING_KEY"))
+	if path := os.Getenv("RECEIPT_SIGNING_KEY_FILE"); len(data) == 0 && path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			panic("reading RECEIPT_SIGNING_KEY_FILE: " + err.Error())
+		}
+	}
+
+	if len(data) == 0 {
+		log.Print("no receipt signing key configured, using an ephemeral key")
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			panic("crypto/rand unavailable: " + err.Error())
+		}
+		return key
+	}
+
+	key, err := parseSigningKey(data)
+	if err != nil {
+		panic(err.Error())
+	}
+	return key
+}
+
+// parseSigningKey decodes an Ed25519 private key in any supported format
+func parseSigningKey(data []byte) (ed25519.PrivateKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errInvalidSigningKey
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errInvalidSigningKey
+		}
+		return key, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errInvalidSigningKey
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, errInvalidSigningKey
+	}
+}
+
+// keyID names a public key by the first 8 bytes of its SHA-256 hash
+func keyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// canonicalJSON encodes v with sorted object keys, no insignificant
+// whitespace and no HTML escaping
+func canonicalJSON(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(generic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// signReceipt signs a drawn reading with key
+func signReceipt(key ed25519.PrivateKey, drawnCards []Card, options receiptOptions, seed string, now time.Time) (*drawReceipt, error) {
+	timestamp := now.UTC().Format(time.RFC3339)
+	payload, err := canonicalJSON(receiptPayload{
+		DrawnCards: drawnCards,
+		Options:    options,
+		Seed:       seed,
+		Timestamp:  timestamp,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &drawReceipt{
+		KeyID:     keyID(key.Public().(ed25519.PublicKey)),
+		Algorithm: "Ed25519",
+		Timestamp: timestamp,
+		Options:   options,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	}, nil
+}
+
+// verifyReceipt reports whether resp carries a valid receipt signed by pub
+func verifyReceipt(pub ed25519.PublicKey, resp drawResponse) bool {
+	if resp.Receipt == nil {
+		return false
+	}
+	signature, err := base64.StdEncoding.DecodeString(resp.Receipt.Signature)
+	if err != nil {
+		return false
+	}
+	payload, err := canonicalJSON(receiptPayload{
+		DrawnCards: resp.DrawnCards,
+		Options:    resp.Receipt.Options,
+		Seed:       resp.Seed,
+		Timestamp:  resp.Receipt.Timestamp,
+	})
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, payload, signature)
+}
+
+// keysHandler publishes the public key used to sign receipts
+func keysHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "GET" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_get_only"))
+	}
+
+	pub := signingKey.Public().(ed25519.PublicKey)
+	return jsonResponse(http.StatusOK, keysResponse{
+		Keys: []publicKey{{
+			KeyID:     keyID(pub),
+			Algorithm: "Ed25519",
+			PublicKey: base64.StdEncoding.EncodeToString(pub),
+		}},
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"testing"
+)
+
+func publishedKey(t *testing.T) ed25519.PublicKey {
+	t.Helper()
+
+	var keys keysResponse
+	call(t, apiRequest("GET", "/keys", "", nil), 200, &keys)
+	if len(keys.Keys) != 1 || keys.Keys[0].Algorithm != "Ed25519" {
+		t.Fatalf("Expected one Ed25519 key, got %+v", keys.Keys)
+	}
+
+	pub, err := base64.StdEncoding.DecodeString(keys.Keys[0].PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to decode public key: %v", err)
+	}
+	return ed25519.PublicKey(pub)
+}
+
+func TestDrawHandler_SignedReceipt(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 5}`, nil), 200, &drawResp)
+	if drawResp.Receipt == nil {
+		t.Fatal("Expected receipt in draw response")
+	}
+
+	pub := publishedKey(t)
+	if drawResp.Receipt.KeyID != keyID(pub) {
+		t.Errorf("Expected keyId '%s', got '%s'", keyID(pub), drawResp.Receipt.KeyID)
+	}
+	if drawResp.Receipt.Options.NumCards != 5 || drawResp.Receipt.Options.DeckSize != "Full Deck" {
+		t.Errorf("Expected request options in receipt, got %+v", drawResp.Receipt.Options)
+	}
+	if !verifyReceipt(pub, drawResp) {
+		t.Error("Expected receipt to verify against published key")
+	}
+
+	// Any change to the reading must break the signature
+	drawResp.DrawnCards[0].Reversed = !drawResp.DrawnCards[0].Reversed
+	if verifyReceipt(pub, drawResp) {
+		t.Error("Expected tampered reading to fail verification")
+	}
+}
+
+func TestDrawHandler_ReceiptSignsEveryOption(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "locale": "fr", "significator": "cups-13", "filter": {"arcana": "minor"}, "reversal": {"probability": 0.5}, "shuffle": {"strategy": "riffle", "passes": 2}, "copies": 2, "numCards": 4}`, nil), 200, &drawResp)
+	pub := publishedKey(t)
+	if !verifyReceipt(pub, drawResp) {
+		t.Fatal("Expected receipt to verify against published key")
+	}
+
+	// The receipt fills in the defaults the reading was dealt with
+	signed := drawResp.Receipt.Options
+	if signed.Tradition != "rws" || signed.Locale != "fr" || signed.NumCards != 4 || signed.Copies != 2 {
+		t.Errorf("Expected the dealt tradition, locale, numCards and copies, got %+v", signed)
+	}
+
+	// Changing any option, or the locale, must break the signature
+	changes := map[string]func(o *receiptOptions){
+		"locale":       func(o *receiptOptions) { o.Locale = "de" },
+		"significator": func(o *receiptOptions) { o.Significator = &significator{ID: "cups-12"} },
+		"filter":       func(o *receiptOptions) { o.Filter = nil },
+		"reversal":     func(o *receiptOptions) { o.Reversal = &reversal{Policy: "majors"} },
+		"shuffle":      func(o *receiptOptions) { o.Shuffle = nil },
+		"copies":       func(o *receiptOptions) { o.Copies = 1 },
+		"replacement":  func(o *receiptOptions) { o.Replacement = true },
+		"customSpread": func(o *receiptOptions) { o.CustomSpread = &spread{Positions: []*spreadPosition{{Name: "a"}}} },
+	}
+	for name, change := range changes {
+		tampered := drawResp
+		receipt := *drawResp.Receipt
+		change(&receipt.Options)
+		tampered.Receipt = &receipt
+		if verifyReceipt(pub, tampered) {
+			t.Errorf("%s: expected a changed option to fail verification", name)
+		}
+	}
+}
+
+func TestCanonicalJSON_SortsKeys(t *testing.T) {
+	got, err := canonicalJSON(receiptOptions{deckOptions: deckOptions{DeckSize: "Cups & Wands", DeckReverse: "Upright only", NumCards: 3, Tradition: "rws"}, Locale: "en"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := `{"deckReverse":"Upright only","deckSize":"Cups & Wands","locale":"en","numCards":3,"tradition":"rws"}`
+	if string(got) != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestParseSigningKey(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	want := ed25519.NewKeyFromSeed(seed)
+
+	der, err := x509.MarshalPKCS8PrivateKey(want)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	inputs := map[string][]byte{
+		"seed":        []byte(base64.StdEncoding.EncodeToString(seed)),
+		"private key": []byte(base64.StdEncoding.EncodeToString(want) + "\n"),
+		"pem":         pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+	}
+	for name, data := range inputs {
+		key, err := parseSigningKey(data)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+			continue
+		}
+		if !key.Equal(want) {
+			t.Errorf("%s: parsed key does not match", name)
+		}
+	}
+
+	if _, err := parseSigningKey([]byte("not a key")); err != errInvalidSigningKey {
+		t.Errorf("Expected errInvalidSigningKey, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Formats a reading can be rendered in, chosen by the format field or the
+// Accept header
+const (
+	formatJSON     = "json"
+	formatText     = "text"
+	formatMarkdown = "markdown"
+	formatHTML     = "html"
+	formatCSV      = "csv"
+)
+
+// readingMediaTypes maps each format to the media type it is served as
+var readingMediaTypes = map[string]string{
+	formatJSON:     "application/json",
+	formatText:     "text/plain",
+	formatMarkdown: "text/markdown",
+	formatHTML:     "text/html",
+	formatCSV:      "text/csv",
+}
+
+var errInvalidFormat = errors.New("unknown format")
+
+// readingTemplate is satisfied by both text and HTML templates
+type readingTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+//go:embed data/templates/*
+var templateFiles embed.FS
+
+// readingTemplates holds the template for each format other than JSON
+var readingTemplates = loadReadingTemplates()
+
+func loadReadingTemplates() map[string]readingTemplate {
+	funcs := template.FuncMap{"csv": csvField}
+	parse := func(name string) *template.Template {
+		return template.Must(template.New(name).Funcs(funcs).ParseFS(templateFiles, "data/templates/"+name))
+	}
+	return map[string]readingTemplate{
+		formatText:     parse("reading.txt"),
+		formatMarkdown: parse("reading.md"),
+		formatCSV:      parse("reading.csv"),
+		// html/template escapes card names, meanings and URLs for the page
+		formatHTML: htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "data/templates/reading.html")),
+	}
+}
+
+// readingView is what the templates render: the drawn cards with their
+// display names and orientation in the reading's locale
+type readingView struct {
+	Title     string
+	Cards     []readingCard
+	Message   string
+	Seed      string
+	Tradition string
+	Spread    string
+}
+
+// readingCard is one card of a reading. Index counts from 1 in the order
+// the cards were drawn; Position is the name of the spread position, if any.
+type readingCard struct {
+	Index       int
+	Position    string
+	Name        string
+	Orientation string
+	Card        Card
+}
+
+func newReadingView(resp drawResponse, loc localizer) readingView {
+	view := readingView{
+		Title:     loc.message("reading_title"),
+		Message:   resp.Message,
+		Seed:      resp.Seed,
+		Tradition: resp.Tradition,
+		Spread:    resp.Spread,
+	}
+	for i, card := range resp.DrawnCards {
+		rc := readingCard{
+			Index:       i + 1,
+			Name:        displayName(card),
+			Orientation: loc.message("card_upright"),
+			Card:        card,
+		}
+		if card.Position != nil {
+			rc.Position = card.Position.Name
+		}
+		if card.Reversed {
+			rc.Orientation = loc.message("card_reversed")
+		}
+		view.Cards = append(view.Cards, rc)
+	}
+	return view
+}
+
+// displayName returns the name a card is shown with, such as "Ace of Cups"
+// or "XIII Death"
+func displayName(card Card) string {
+	return strings.TrimSpace(card.Number + " " + card.NameSuit)
+}
+
+// csvField quotes s for a CSV record when it holds a comma, quote, line
+// break or surrounding space
+func csvField(s string) string {
+	if strings.ContainsAny(s, ",\"\r\n") || strings.TrimSpace(s) != s {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
+
+// negotiateFormat picks the format of a reading: format when the request
+// names one, otherwise the supported media type the Accept value prefers
+// most, and JSON when it prefers none of them
+func negotiateFormat(format, accept string) (string, error) {
+	if format != "" {
+		format = strings.ToLower(format)
+		if _, ok := readingMediaTypes[format]; !ok {
+			return "", errInvalidFormat
+		}
+		return format, nil
+	}
+
+	for _, mediaRange := range acceptRanges(accept) {
+		switch mediaRange {
+		case "*/*", "application/*":
+			return formatJSON, nil
+		case "text/*":
+			return formatText, nil
+		}
+		for format, mediaType := range readingMediaTypes {
+			if mediaRange == mediaType {
+				return format, nil
+			}
+		}
+	}
+	return formatJSON, nil
+}
+
+// acceptRanges returns the media ranges of an Accept value, such as
+// "text/html" or "image/*", most preferred first and without those it
+// refuses with q=0
+func acceptRanges(accept string) []string {
+	type weighted struct {
+		mediaRange string
+		q          float64
+	}
+
+	var ranges []weighted
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaRange == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, weighted{mediaRange, q})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	preferred := make([]string, len(ranges))
+	for i, w := range ranges {
+		preferred[i] = w.mediaRange
+	}
+	return preferred
+}
+
+// readingResponse sends resp as JSON or renders it in format. Rendered
+// readings carry the cards, their positions and meanings and any message,
+// but not the receipt, proof or deck state, which only JSON clients can use.
+func readingResponse(format string, resp drawResponse, loc localizer) (events.APIGatewayV2HTTPResponse, error) {
+	if format == formatJSON {
+		result, err := jsonResponse(http.StatusOK, resp)
+		result.Headers["Vary"] = "Accept"
+		return result, err
+	}
+
+	var body strings.Builder
+	if err := readingTemplates[format].Execute(&body, newReadingView(resp, loc)); err != nil {
+		return errorResult(http.StatusInternalServerError, "internal_error", loc.message("render_failed"))
+	}
+
+	headers := corsHeaders()
+	headers["Content-Type"] = readingMediaTypes[format] + "; charset=utf-8"
+	headers["Vary"] = "Accept"
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       body.String(),
+	}, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		format string
+		accept string
+		want   string
+	}{
+		{"", "", formatJSON},
+		{"", "application/json", formatJSON},
+		{"", "*/*", formatJSON},
+		{"", "text/plain", formatText},
+		{"", "text/markdown, text/plain;q=0.5", formatMarkdown},
+		{"", "text/plain;q=0.4, text/html;q=0.9", formatHTML},
+		{"", "text/csv; charset=utf-8", formatCSV},
+		{"", "text/*", formatText},
+		{"", "image/png, text/markdown;q=0", formatJSON},
+		{"HTML", "text/plain", formatHTML},
+		{"json", "text/csv", formatJSON},
+	}
+	for _, c := range cases {
+		got, err := negotiateFormat(c.format, c.accept)
+		if err != nil || got != c.want {
+			t.Errorf("format %q, Accept %q: expected %s, got %s (%v)", c.format, c.accept, c.want, got, err)
+		}
+	}
+
+	if _, err := negotiateFormat("yaml", ""); err != errInvalidFormat {
+		t.Errorf("Expected errInvalidFormat, got %v", err)
+	}
+}
+
+func TestDrawHandler_RenderedFormats(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	body := `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "spread": "three-card", "includeMeanings": true, "seed": "render"}`
+	var reading drawResponse
+	call(t, apiRequest("POST", "/draw", body, nil), 200, &reading)
+
+	cases := []struct {
+		accept      string
+		contentType string
+	}{
+		{"text/plain", "text/plain; charset=utf-8"},
+		{"text/markdown", "text/markdown; charset=utf-8"},
+		{"text/html", "text/html; charset=utf-8"},
+		{"text/csv", "text/csv; charset=utf-8"},
+	}
+	for _, c := range cases {
+		req := apiRequest("POST", "/draw", body, nil)
+		req.Headers = map[string]string{"Accept": c.accept}
+		resp, err := handleRequest(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp.StatusCode != 200 || resp.Headers["Content-Type"] != c.contentType {
+			t.Fatalf("%s: expected 200 %s, got %d %s: %s", c.accept, c.contentType, resp.StatusCode, resp.Headers["Content-Type"], resp.Body)
+		}
+		if resp.Headers["Vary"] != "Accept" {
+			t.Errorf("%s: expected Vary: Accept, got %q", c.accept, resp.Headers["Vary"])
+		}
+
+		// Every card, its position and its meaning appear in the reading
+		for _, card := range reading.DrawnCards {
+			for _, want := range []string{card.NameSuit, card.Position.Name, card.Meaning.Meaning} {
+				if !strings.Contains(resp.Body, want) {
+					t.Errorf("%s: expected %q in the reading, got %s", c.accept, want, resp.Body)
+				}
+			}
+			if c.accept == "text/html" && !strings.Contains(resp.Body, `<img src="`+card.Image+`"`) {
+				t.Errorf("Expected an image tag for %s, got %s", card.Image, resp.Body)
+			}
+		}
+	}
+}
+
+func TestDrawHandler_RenderedCSV(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	resp, err := handleRequest(apiRequest("POST", "/draw", `{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "numCards": 5, "includeMeanings": true, "format": "csv"}`, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Meanings hold commas, which must be quoted for the rows to parse
+	records, err := csv.NewReader(strings.NewReader(resp.Body)).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV reading: %v", err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("Expected a header and 5 rows, got %d records", len(records))
+	}
+	if strings.Join(records[0], ",") != "index,position,id,name,orientation,image,meaning" {
+		t.Errorf("Unexpected CSV header %v", records[0])
+	}
+	for i, record := range records[1:] {
+		if record[0] != strconv.Itoa(i+1) || !strings.HasPrefix(record[2], "major-") || record[4] != "upright" || record[6] == "" {
+			t.Errorf("Unexpected CSV row %v", record)
+		}
+	}
+}
+
+func TestDrawHandler_RenderedHTMLEscapes(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	req := apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "customSpread": {"name": "x", "positions": [{"name": "<script>alert(1)</script>"}]}}`, nil)
+	req.Headers = map[string]string{"Accept": "text/html"}
+	resp, err := handleRequest(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Contains(resp.Body, "<script>") || !strings.Contains(resp.Body, "&lt;script&gt;") {
+		t.Errorf("Expected the position name to be escaped, got %s", resp.Body)
+	}
+}
+
+func TestDrawHandler_InvalidFormat(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	req := apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "format": "yaml"}`, nil)
+	req.Headers = map[string]string{"Accept": "text/plain"}
+	resp, err := handleRequest(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 400 || resp.Headers["Content-Type"] != "application/json" || !strings.Contains(resp.Body, `"invalid_format"`) {
+		t.Errorf("Expected a JSON 400 invalid_format, got %d %s: %s", resp.StatusCode, resp.Headers["Content-Type"], resp.Body)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	errInvalidJSON = errors.New("invalid JSON body")
+	errInvalidForm = errors.New("invalid form body")
+)
+
+// decodeRequest fills v, a pointer to a request struct, from the query
+// string and then from the body, so fields in the body win. The body may be
+// base64 encoded, as API Gateway sends binary and form posts, and is read as
+// form data when its Content-Type is application/x-www-form-urlencoded and
+// as JSON otherwise. An empty body leaves v as the query string set it.
+func decodeRequest(req events.APIGatewayV2HTTPRequest, v any) error {
+	if len(req.QueryStringParameters) > 0 {
+		query := url.Values{}
+		for key, value := range req.QueryStringParameters {
+			query.Set(key, value)
+		}
+		if err := decodeForm(query, v); err != nil {
+			return err
+		}
+	}
+
+	body := req.Body
+	if req.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return errInvalidForm
+		}
+		body = string(decoded)
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(header(req, "Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(body)
+		if err != nil {
+			return errInvalidForm
+		}
+		return decodeForm(form, v)
+	}
+
+	if err := json.Unmarshal([]byte(body), v); err != nil {
+		return errInvalidJSON
+	}
+	return nil
+}
+
+// decodeForm fills v from form values by building the equivalent JSON
+// object. Values of string fields are taken as text; other values, such as
+// numCards=3, includeMeanings=true or filter={"suits":["cups"]}, are taken
+// as JSON, falling back to text for fields like significator that accept a
+// bare string.
+func decodeForm(form url.Values, v any) error {
+	kinds := jsonFieldKinds(reflect.TypeOf(v).Elem())
+
+	object := map[string]json.RawMessage{}
+	for key, values := range form {
+		kind, ok := kinds[key]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		value := values[len(values)-1]
+		if kind != reflect.String && json.Valid([]byte(value)) {
+			object[key] = json.RawMessage(value)
+			continue
+		}
+		object[key], _ = json.Marshal(value)
+	}
+
+	data, _ := json.Marshal(object)
+	if err := json.Unmarshal(data, v); err != nil {
+		return errInvalidForm
+	}
+	return nil
+}
+
+// jsonFieldKinds maps the JSON names of the fields of struct type t,
+// including those of embedded structs, to their kinds
+func jsonFieldKinds(t reflect.Type) map[string]reflect.Kind {
+	kinds := map[string]reflect.Kind{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch {
+		case name == "-":
+		case field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct:
+			for inner, kind := range jsonFieldKinds(field.Type) {
+				kinds[inner] = kind
+			}
+		case !field.IsExported():
+		case name == "":
+			kinds[field.Name] = field.Type.Kind()
+		default:
+			kinds[name] = field.Type.Kind()
+		}
+	}
+	return kinds
+}
+
+// decodeErrorResult builds the error response for a decodeRequest error
+func decodeErrorResult(loc localizer, err error) (events.APIGatewayV2HTTPResponse, error) {
+	if err == errInvalidForm {
+		return errorResult(http.StatusBadRequest, "invalid_request", loc.message("invalid_form"))
+	}
+	return errorResult(http.StatusBadRequest, "invalid_request", loc.message("invalid_json"))
+}
+
+// fromProxyRequest converts a payload format 1.0 event to the format 2.0
+// request the handlers read. Repeated headers and query parameters are
+// joined with commas, as format 2.0 joins them.
+func fromProxyRequest(proxyReq events.APIGatewayProxyRequest) events.APIGatewayV2HTTPRequest {
+	req := events.APIGatewayV2HTTPRequest{
+		Version:               "2.0",
+		RouteKey:              proxyReq.HTTPMethod + " " + proxyReq.Resource,
+		RawPath:               proxyReq.Path,
+		Headers:               joinValues(proxyReq.Headers, proxyReq.MultiValueHeaders),
+		QueryStringParameters: joinValues(proxyReq.QueryStringParameters, proxyReq.MultiValueQueryStringParameters),
+		PathParameters:        proxyReq.PathParameters,
+		StageVariables:        proxyReq.StageVariables,
+		Body:                  proxyReq.Body,
+		IsBase64Encoded:       proxyReq.IsBase64Encoded,
+	}
+	req.RequestContext.HTTP.Method = proxyReq.HTTPMethod
+	req.RequestContext.HTTP.Path = proxyReq.Path
+	return req
+}
+
+// joinValues merges single and multi-valued maps of a format 1.0 event,
+// joining the multiple values of a key with commas
+func joinValues(single map[string]string, multi map[string][]string) map[string]string {
+	if len(single) == 0 && len(multi) == 0 {
+		return nil
+	}
+	joined := map[string]string{}
+	for key, value := range single {
+		joined[key] = value
+	}
+	for key, values := range multi {
+		if len(values) > 0 {
+			joined[key] = strings.Join(values, ",")
+		}
+	}
+	return joined
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func formRequest(body string) events.APIGatewayV2HTTPRequest {
+	req := apiRequest("POST", "/draw", body, nil)
+	req.Headers = map[string]string{"content-type": "application/x-www-form-urlencoded; charset=utf-8"}
+	return req
+}
+
+func TestDecodeRequest_Bodies(t *testing.T) {
+	encoded := formRequest(base64.StdEncoding.EncodeToString([]byte("deckSize=Full+Deck&deckReverse=Upright+only&numCards=3")))
+	encoded.IsBase64Encoded = true
+
+	cases := []struct {
+		name string
+		req  events.APIGatewayV2HTTPRequest
+	}{
+		{"json", apiRequest("POST", "/draw", `{"deckSize":"Full Deck","deckReverse":"Upright only","numCards":3}`, nil)},
+		{"form", formRequest("deckSize=Full+Deck&deckReverse=Upright+only&numCards=3")},
+		{"base64 form", encoded},
+	}
+	for _, c := range cases {
+		var drawReq drawRequest
+		if err := decodeRequest(c.req, &drawReq); err != nil {
+			t.Fatalf("%s: expected no error, got %v", c.name, err)
+		}
+		if drawReq.DeckSize != "Full Deck" || drawReq.DeckReverse != "Upright only" || drawReq.NumCards != 3 {
+			t.Errorf("%s: expected Full Deck, Upright only, 3 cards, got %+v", c.name, drawReq.deckOptions)
+		}
+	}
+}
+
+func TestDecodeRequest_FormValues(t *testing.T) {
+	req := formRequest(`numCards=5&includeMeanings=true&significator=major-00-fool&filter={"suits":["cups"]}&seed=42`)
+
+	var drawReq drawRequest
+	if err := decodeRequest(req, &drawReq); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if drawReq.NumCards != 5 || !drawReq.IncludeMeanings {
+		t.Errorf("Expected 5 cards with meanings, got %d, %v", drawReq.NumCards, drawReq.IncludeMeanings)
+	}
+	if drawReq.Significator == nil || drawReq.Significator.ID != "major-00-fool" {
+		t.Errorf("Expected significator major-00-fool, got %+v", drawReq.Significator)
+	}
+	if drawReq.Filter == nil || len(drawReq.Filter.Suits) != 1 || drawReq.Filter.Suits[0] != "cups" {
+		t.Errorf("Expected a cups filter, got %+v", drawReq.Filter)
+	}
+	if drawReq.Seed != "42" {
+		t.Errorf("Expected seed to stay text, got %q", drawReq.Seed)
+	}
+}
+
+func TestDecodeRequest_QueryParameters(t *testing.T) {
+	req := apiRequest("POST", "/draw", `{"numCards":4}`, nil)
+	req.QueryStringParameters = map[string]string{"deckSize": "Major Arcana only", "numCards": "2"}
+
+	var drawReq drawRequest
+	if err := decodeRequest(req, &drawReq); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if drawReq.DeckSize != "Major Arcana only" {
+		t.Errorf("Expected deckSize from the query string, got %q", drawReq.DeckSize)
+	}
+	if drawReq.NumCards != 4 {
+		t.Errorf("Expected the body to win over the query string, got %d cards", drawReq.NumCards)
+	}
+}
+
+func TestDecodeRequest_Invalid(t *testing.T) {
+	badBase64 := formRequest("not base64!")
+	badBase64.IsBase64Encoded = true
+
+	cases := []struct {
+		name string
+		req  events.APIGatewayV2HTTPRequest
+		err  error
+	}{
+		{"json", apiRequest("POST", "/draw", `{"numCards":`, nil), errInvalidJSON},
+		{"form", formRequest("numCards=three"), errInvalidForm},
+		{"escape", formRequest("deckSize=%zz"), errInvalidForm},
+		{"base64", badBase64, errInvalidForm},
+	}
+	for _, c := range cases {
+		var drawReq drawRequest
+		if err := decodeRequest(c.req, &drawReq); err != c.err {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+// The dev_tooling events are payload format 1.0 events posting the
+// form-urlencoded bodies a plain HTML form sends, one of them base64 encoded
+// as API Gateway delivers it
+func TestHandleEvent_DevToolingPayloads(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	for _, name := range []string{"payload.json", "simulated-draw.json"} {
+		data, err := os.ReadFile("../dev_tooling/test_scripts/" + name)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+
+		resp, err := handleEvent(data)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("%s: expected status 200, got %d: %s", name, resp.StatusCode, resp.Body)
+		}
+
+		var drawResp drawResponse
+		if err := json.Unmarshal([]byte(resp.Body), &drawResp); err != nil {
+			t.Fatalf("Failed to parse draw response: %v", err)
+		}
+		if len(drawResp.DrawnCards) != 8 {
+			t.Errorf("%s: expected 8 cards, got %d", name, len(drawResp.DrawnCards))
+		}
+	}
+}
+
+func TestHandleEvent_PayloadFormats(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	v2, _ := json.Marshal(apiRequest("GET", "/decks", "", nil))
+	v1 := `{"resource": "/cards/{id}", "path": "/cards/major-00-fool", "httpMethod": "GET",
+		"pathParameters": {"id": "major-00-fool"},
+		"multiValueQueryStringParameters": {"locale": ["fr"]}}`
+	cases := []struct {
+		name  string
+		event string
+		want  string
+	}{
+		{"format 2.0", string(v2), `"decks"`},
+		{"format 1.0", v1, `"Le Fou"`},
+	}
+	for _, c := range cases {
+		resp, err := handleEvent(json.RawMessage(c.event))
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", c.name, err)
+		}
+		if resp.StatusCode != 200 || !strings.Contains(resp.Body, c.want) {
+			t.Errorf("%s: expected 200 with %s, got %d: %s", c.name, c.want, resp.StatusCode, resp.Body)
+		}
+	}
+
+	// Format 1.0 methods are checked like any other
+	resp, _ := handleEvent(json.RawMessage(`{"path": "/decks", "httpMethod": "DELETE"}`))
+	if resp.StatusCode != 405 {
+		t.Errorf("Expected 405 for DELETE /decks, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// reversal sets how cards come to be reversed when deckReverse is "Upright
+// and reversed". Probability is the chance of a card being reversed, 0.5 if
+// not set. Policy limits reversals to the "majors" or "minors", leaving the
+// other cards upright, or "all" by default. Mode "independent", the default,
+// decides each card on its own; "physical" turns one packet of the deck
+// around during the shuffle, as a reader would, so the number reversed varies
+// from draw to draw around Probability.
+type reversal struct {
+	Probability *float64 `json:"probability,omitempty"`
+	Policy      string   `json:"policy,omitempty"`
+	Mode        string   `json:"mode,omitempty"`
+}
+
+const (
+	reversalAll    = "all"
+	reversalMajors = "majors"
+	reversalMinors = "minors"
+
+	reversalIndependent = "independent"
+	reversalPhysical    = "physical"
+)
+
+var errInvalidReversal = errors.New("invalid reversal")
+
+// validate checks the probability is between 0 and 1 and the policy and mode
+// are known
+func (r *reversal) validate() error {
+	if r.Probability != nil && (*r.Probability < 0 || *r.Probability > 1 || math.IsNaN(*r.Probability)) {
+		return errInvalidReversal
+	}
+	switch r.Policy {
+	case "", reversalAll, reversalMajors, reversalMinors:
+	default:
+		return errInvalidReversal
+	}
+	switch r.Mode {
+	case "", reversalIndependent, reversalPhysical:
+	default:
+		return errInvalidReversal
+	}
+	return nil
+}
+
+func (r *reversal) probability() float64 {
+	if r.Probability == nil {
+		return 0.5
+	}
+	return *r.Probability
+}
+
+// eligible reports whether the policy lets card be reversed
+func (r *reversal) eligible(card Card) bool {
+	switch r.Policy {
+	case reversalMajors:
+		return card.Arcana == ArcanaMajor
+	case reversalMinors:
+		return card.Arcana == ArcanaMinor
+	default:
+		return true
+	}
+}
+
+// apply reverses cards according to r, drawing from src
+func (r *reversal) apply(cards []Card, src RandomSource) []Card {
+	if r.Mode == reversalPhysical {
+		return r.turnPacket(cards, src)
+	}
+
+	p := r.probability()
+	for i := range cards {
+		cards[i].Reversed = false
+		if r.eligible(cards[i]) {
+			cards[i].Reversed = uniformFloat(src) < p
+		}
+	}
+	return cards
+}
+
+// turnPacket splits off a packet of random cards and turns it around. The
+// packet is a uniformly random fraction of the deck, from 0 to twice the
+// probability (or from 2p-1 to the whole deck above one half), so that on
+// average the probability of the cards are turned. Only eligible cards in
+// the packet end up reversed.
+func (r *reversal) turnPacket(cards []Card, src RandomSource) []Card {
+	p := r.probability()
+	lo, hi := 0.0, 2*p
+	if p > 0.5 {
+		lo, hi = 2*p-1, 1
+	}
+	size := int(math.Round((lo + (hi-lo)*uniformFloat(src)) * float64(len(cards))))
+
+	// Pick the packet with a partial Fisher-Yates shuffle of the positions
+	order := make([]int, len(cards))
+	for i := range order {
+		order[i] = i
+	}
+	for i := range cards {
+		cards[i].Reversed = false
+	}
+	for i := 0; i < size; i++ {
+		j := i + uniformInt(src, len(order)-i)
+		order[i], order[j] = order[j], order[i]
+		if card := &cards[order[i]]; r.eligible(*card) {
+			card.Reversed = true
+		}
+	}
+	return cards
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"testing"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+// reversedRate deals the full deck trials times under rev and returns the
+// share of eligible cards reversed, failing if an ineligible card is reversed
+func reversedRate(t *testing.T, rev *reversal, trials int) float64 {
+	t.Helper()
+
+	src := newPCGSource(15)
+	reversed, eligible := 0, 0
+	for i := 0; i < trials; i++ {
+		deck, _, err := getDeck("Full Deck", "Upright and reversed", "", nil, rev, src)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, card := range deck {
+			if !rev.eligible(card) {
+				if card.Reversed {
+					t.Fatalf("Expected %s upright under policy %q", card.ID, rev.Policy)
+				}
+				continue
+			}
+			eligible++
+			if card.Reversed {
+				reversed++
+			}
+		}
+	}
+	return float64(reversed) / float64(eligible)
+}
+
+func TestReversal_Probability(t *testing.T) {
+	cases := []*reversal{
+		{Probability: floatPtr(0.2)},
+		{Probability: floatPtr(0.2), Mode: reversalPhysical},
+		{Probability: floatPtr(0.8), Mode: reversalPhysical},
+		{Policy: reversalMajors},
+		{Probability: floatPtr(0.3), Policy: reversalMinors, Mode: reversalPhysical},
+	}
+	for _, rev := range cases {
+		if rate := reversedRate(t, rev, 2000); math.Abs(rate-rev.probability()) > 0.02 {
+			t.Errorf("Expected a reversal rate near %.2f for %+v, got %.3f", rev.probability(), rev, rate)
+		}
+	}
+
+	if rate := reversedRate(t, &reversal{Probability: floatPtr(0)}, 10); rate != 0 {
+		t.Errorf("Expected no reversals at probability 0, got %.3f", rate)
+	}
+	if rate := reversedRate(t, &reversal{Probability: floatPtr(1)}, 10); rate != 1 {
+		t.Errorf("Expected every card reversed at probability 1, got %.3f", rate)
+	}
+}
+
+func TestReversal_PhysicalCountVaries(t *testing.T) {
+	rev := &reversal{Probability: floatPtr(0.25), Mode: reversalPhysical}
+	src := newPCGSource(16)
+
+	counts := map[int]bool{}
+	for i := 0; i < 50; i++ {
+		deck, _, _ := getDeck("Full Deck", "Upright and reversed", "", nil, rev, src)
+		n := 0
+		for _, card := range deck {
+			if card.Reversed {
+				n++
+			}
+		}
+		if n > 39 {
+			t.Errorf("Expected at most half the deck turned at probability 0.25, got %d", n)
+		}
+		counts[n] = true
+	}
+	if len(counts) < 10 {
+		t.Errorf("Expected the number reversed to vary, got %d distinct counts", len(counts))
+	}
+}
+
+func TestGetDeck_InvalidReversal(t *testing.T) {
+	cases := map[string]struct {
+		deckReverse string
+		rev         reversal
+	}{
+		"probability":  {"Upright and reversed", reversal{Probability: floatPtr(1.5)}},
+		"policy":       {"Upright and reversed", reversal{Policy: "courts"}},
+		"mode":         {"Upright and reversed", reversal{Mode: "riffle"}},
+		"upright only": {"Upright only", reversal{Probability: floatPtr(0.2)}},
+	}
+	for name, c := range cases {
+		if _, _, err := getDeck("Full Deck", c.deckReverse, "", nil, &c.rev, defaultSource); err != errInvalidReversal {
+			t.Errorf("%s: expected errInvalidReversal, got %v", name, err)
+		}
+	}
+}
+
+func TestDrawHandler_Reversal(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "numCards": 78,
+		"seed": "reversal", "reversal": {"probability": 1, "policy": "majors"}}`, nil), 200, &drawResp)
+	for _, card := range drawResp.DrawnCards {
+		if card.Reversed != (card.Arcana == ArcanaMajor) {
+			t.Errorf("Expected only the majors reversed, got %s reversed=%v", card.ID, card.Reversed)
+		}
+	}
+
+	resp, err := drawHandler(apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "reversal": {"probability": 0.3}}`, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var errorResp errorResponse
+	if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+		t.Fatalf("Failed to parse error response: %v", err)
+	}
+	if resp.StatusCode != 400 || errorResp.Error != "invalid_reversal" {
+		t.Errorf("Expected 400 invalid_reversal, got %d %s", resp.StatusCode, errorResp.Error)
+	}
+}
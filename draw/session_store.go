@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// sessionStore keeps deck sessions between requests. Update must apply fn
+// to the stored session and save the result as one step, so that two draws
+// from the same session never deal the same card.
+type sessionStore interface {
+	// Create saves a new session
+	Create(s *session) error
+	// Load returns the session with the given ID, or errSessionNotFound
+	Load(id string) (*session, error)
+	// Update loads the session, applies fn and saves it unless fn fails
+	Update(id string, fn func(*session) error) (*session, error)
+}
+
+const (
+	// sessionTTL is how long a session lasts after it was last used
+	sessionTTL = 24 * time.Hour
+	// sweepInterval is how often a store removes the sessions that have
+	// expired, so sessions that are never used again do not pile up
+	sweepInterval = time.Minute
+)
+
+var errSessionNotFound = errors.New("session not found")
+
+// sessions is the store configured by SESSION_STORE: "memory", the default,
+// or "file" to keep one JSON file per session in SESSION_DIR
+var sessions = loadSessionStore()
+
+func loadSessionStore() sessionStore {
+	switch kind := os.Getenv("SESSION_STORE"); kind {
+	case "", "memory":
+		return newMemoryStore()
+	case "file":
+		dir := os.Getenv("SESSION_DIR")
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "tarot-sessions")
+		}
+		store, err := newFileStore(dir)
+		if err != nil {
+			panic("opening SESSION_DIR: " + err.Error())
+		}
+		return store
+	default:
+		log.Printf("unknown SESSION_STORE %q, keeping sessions in memory", kind)
+		return newMemoryStore()
+	}
+}
+
+// expired reports whether s was last used more than sessionTTL before now
+func (s *session) expired(now time.Time) bool {
+	return now.Sub(s.UpdatedAt) > sessionTTL
+}
+
+// memoryStore keeps sessions in the memory of one Lambda container, so a
+// session is lost when the container is recycled and is not visible to
+// other containers
+type memoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	swept    time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{sessions: map[string]*session{}}
+}
+
+func (m *memoryStore) Create(s *session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(time.Now())
+	m.sessions[s.ID] = s.clone()
+	return nil
+}
+
+func (m *memoryStore) Load(id string) (*session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+	s, ok := m.sessions[id]
+	if !ok || s.expired(now) {
+		return nil, errSessionNotFound
+	}
+	return s.clone(), nil
+}
+
+func (m *memoryStore) Update(id string, fn func(*session) error) (*session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+	stored, ok := m.sessions[id]
+	if !ok || stored.expired(now) {
+		return nil, errSessionNotFound
+	}
+	s := stored.clone()
+	if err := fn(s); err != nil {
+		return nil, err
+	}
+	m.sessions[id] = s.clone()
+	return s, nil
+}
+
+// sweep drops expired sessions, at most once every sweepInterval, so the
+// map does not grow without bound. The caller must hold m.mu.
+func (m *memoryStore) sweep(now time.Time) {
+	if now.Sub(m.swept) < sweepInterval {
+		return
+	}
+	m.swept = now
+	for id, s := range m.sessions {
+		if s.expired(now) {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+// fileStore keeps each session as a JSON file named after its ID. Updates
+// are serialised within one process and written through a temporary file,
+// so a reader never sees a half-written session.
+type fileStore struct {
+	mu    sync.Mutex
+	dir   string
+	swept time.Time
+}
+
+func newFileStore(dir string) (*fileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+func (f *fileStore) path(id string) string {
+	return filepath.Join(f.dir, id+".json")
+}
+
+func (f *fileStore) Create(s *session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sweep(time.Now())
+	return f.write(s)
+}
+
+func (f *fileStore) Load(id string) (*session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sweep(time.Now())
+	return f.read(id)
+}
+
+func (f *fileStore) Update(id string, fn func(*session) error) (*session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sweep(time.Now())
+	s, err := f.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(s); err != nil {
+		return nil, err
+	}
+	if err := f.write(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// sweep deletes the files of expired sessions, and any temporary file left
+// behind by a failed write, at most once every sweepInterval. A session file
+// is written whenever the session is used, so its modification time tells
+// when it expires without reading it. The caller must hold f.mu.
+func (f *fileStore) sweep(now time.Time) {
+	if now.Sub(f.swept) < sweepInterval {
+		return
+	}
+	f.swept = now
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > sessionTTL {
+			os.Remove(filepath.Join(f.dir, entry.Name()))
+		}
+	}
+}
+
+func (f *fileStore) read(id string) (*session, error) {
+	data, err := os.ReadFile(f.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.expired(time.Now()) {
+		os.Remove(f.path(id))
+		return nil, errSessionNotFound
+	}
+	return &s, nil
+}
+
+func (f *fileStore) write(s *session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, s.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(s.ID))
+}
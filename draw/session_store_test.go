@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testSession(id string) *session {
+	now := time.Now().UTC()
+	return &session{
+		ID:        id,
+		DeckSize:  "Full Deck",
+		Tradition: "rws",
+		Remaining: []Card{{ID: "cups-01"}, {ID: "cups-02"}, {ID: "cups-03"}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// testStore runs the same checks against any sessionStore
+func testStore(t *testing.T, store sessionStore) {
+	t.Helper()
+
+	const id = "00112233445566778899aabbccddeeff"
+	if _, err := store.Load(id); err != errSessionNotFound {
+		t.Errorf("Expected errSessionNotFound, got %v", err)
+	}
+	if err := store.Create(testSession(id)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	s, err := store.Update(id, func(s *session) error {
+		s.Drawn = append(s.Drawn, s.Remaining[0])
+		s.Remaining = s.Remaining[1:]
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(s.Remaining) != 2 || len(s.Drawn) != 1 {
+		t.Errorf("Expected 2 remaining and 1 drawn, got %d and %d", len(s.Remaining), len(s.Drawn))
+	}
+
+	// A failed update leaves the session as it was
+	errStop := errors.New("stop")
+	if _, err := store.Update(id, func(s *session) error {
+		s.Remaining = nil
+		return errStop
+	}); err != errStop {
+		t.Errorf("Expected the update's error, got %v", err)
+	}
+
+	s, err = store.Load(id)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(s.Remaining) != 2 || s.Drawn[0].ID != "cups-01" {
+		t.Errorf("Expected the first update to be kept, got %+v", s)
+	}
+
+	expired := testSession("ffeeddccbbaa99887766554433221100")
+	expired.UpdatedAt = time.Now().Add(-sessionTTL - time.Minute)
+	if err := store.Create(expired); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := store.Load(expired.ID); err != errSessionNotFound {
+		t.Errorf("Expected an expired session to be gone, got %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, newMemoryStore())
+}
+
+func TestMemoryStore_SweepsOnLoad(t *testing.T) {
+	store := newMemoryStore()
+	expired := testSession("ffeeddccbbaa99887766554433221100")
+	expired.UpdatedAt = time.Now().Add(-sessionTTL - time.Minute)
+	store.Create(expired)
+
+	// Looking up any session drops the expired one once sweepInterval passed
+	store.swept = time.Now().Add(-sweepInterval)
+	store.Load("00112233445566778899aabbccddeeff")
+	if len(store.sessions) != 0 {
+		t.Errorf("Expected the expired session to be swept, got %d sessions", len(store.sessions))
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := newFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	testStore(t, store)
+}
+
+func TestFileStore_SweepsExpiredFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newFileStore(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	stale, live := testSession("ffeeddccbbaa99887766554433221100"), testSession("00112233445566778899aabbccddeeff")
+	store.Create(stale)
+	store.Create(live)
+	old := time.Now().Add(-sessionTTL - time.Minute)
+	os.Chtimes(store.path(stale.ID), old, old)
+	leftover := filepath.Join(dir, stale.ID+".123.tmp")
+	os.WriteFile(leftover, nil, 0o600)
+	os.Chtimes(leftover, old, old)
+
+	// Using the store deletes the files that were not written within
+	// sessionTTL, without the stale session having to be read
+	store.swept = time.Now().Add(-sweepInterval)
+	if _, err := store.Load(live.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, path := range []string{store.path(stale.ID), leftover} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted, got %v", filepath.Base(path), err)
+		}
+	}
+	if _, err := os.Stat(store.path(live.ID)); err != nil {
+		t.Errorf("Expected the live session to be kept, got %v", err)
+	}
+}
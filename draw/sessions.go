@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// session is a shuffled deck that cards are drawn from over several
+// requests, without replacement. Remaining holds the cards still in the
+// deck in the order they will be drawn; Drawn holds the cards drawn so far,
+// starting with any significator.
+type session struct {
+	ID        string    `json:"id"`
+	DeckSize  string    `json:"deckSize"`
+	Tradition string    `json:"tradition"`
+	Remaining []Card    `json:"remaining"`
+	Drawn     []Card    `json:"drawn"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// sessionRequest is the body of POST /sessions. numCards, spread and
+// customSpread are ignored, as each draw from the session asks for its own
+// number of cards, and so is replacement, as a session never deals a card
+// twice.
+type sessionRequest struct {
+	deckOptions
+	Seed   string `json:"seed"`
+	Locale string `json:"locale"`
+}
+
+// sessionDrawRequest is the body of POST /sessions/{id}/draw
+type sessionDrawRequest struct {
+	NumCards        int    `json:"numCards"`
+	IncludeMeanings bool   `json:"includeMeanings"`
+	Locale          string `json:"locale"`
+}
+
+// sessionResponse describes a session. DrawnCards holds the cards just
+// drawn for POST /sessions/{id}/draw, and every card drawn so far otherwise.
+type sessionResponse struct {
+	SessionID  string        `json:"sessionId"`
+	DeckSize   string        `json:"deckSize"`
+	Tradition  string        `json:"tradition"`
+	DrawnCards []Card        `json:"drawnCards"`
+	Remaining  int           `json:"remaining"`
+	Message    string        `json:"message"`
+	Locale     string        `json:"locale"`
+	Shuffle    []shuffleStep `json:"shuffle,omitempty"`
+}
+
+var errSessionExhausted = errors.New("no cards left in the session")
+
+// clone returns a copy of s that shares no slices with it
+func (s *session) clone() *session {
+	c := *s
+	c.Remaining = slices.Clone(s.Remaining)
+	c.Drawn = slices.Clone(s.Drawn)
+	return &c
+}
+
+// validSessionID reports whether id has the shape of an id issued by
+// createSessionHandler, which also keeps it safe to use as a file name
+func validSessionID(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == 16
+}
+
+// sessionsHandler dispatches POST /sessions, GET /sessions/{id} and
+// POST /sessions/{id}/draw
+func sessionsHandler(req events.APIGatewayV2HTTPRequest, path string) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "/sessions"), "/")
+	switch {
+	case len(parts) == 1:
+		if req.RequestContext.HTTP.Method != "POST" {
+			return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_post_only"))
+		}
+		return createSessionHandler(req, loc)
+	case !validSessionID(parts[1]):
+		return errorResult(http.StatusNotFound, "session_not_found", loc.message("session_not_found"))
+	case len(parts) == 2:
+		if req.RequestContext.HTTP.Method != "GET" {
+			return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_get_only"))
+		}
+		return getSessionHandler(parts[1], loc)
+	case len(parts) == 3 && parts[2] == "draw":
+		if req.RequestContext.HTTP.Method != "POST" {
+			return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_post_only"))
+		}
+		return sessionDrawHandler(req, parts[1], loc)
+	default:
+		return errorResult(http.StatusNotFound, "session_not_found", loc.message("session_not_found"))
+	}
+}
+
+func createSessionHandler(req events.APIGatewayV2HTTPRequest, loc localizer) (events.APIGatewayV2HTTPResponse, error) {
+	var sessionReq sessionRequest
+	if err := decodeRequest(req, &sessionReq); err != nil {
+		return decodeErrorResult(loc, err)
+	}
+	if sessionReq.Locale != "" {
+		loc = newLocalizer(sessionReq.Locale)
+	}
+	if sessionReq.DeckSize == "" || sessionReq.DeckReverse == "" {
+		return errorResult(http.StatusBadRequest, "missing_parameters", loc.message("missing_deck_options"))
+	}
+
+	opts := sessionReq.deckOptions
+	opts.NumCards, opts.Spread, opts.CustomSpread, opts.Replacement = 0, "", nil, false
+
+	seed := sessionReq.Seed
+	if seed == "" {
+		seed = newSeed()
+	}
+	deck, d, err := shuffledDeck(opts, newSeededSource(seed))
+	if err != nil {
+		return dealErrorResult(loc, err)
+	}
+
+	now := time.Now().UTC()
+	s := &session{
+		ID:        newSeed(),
+		DeckSize:  opts.DeckSize,
+		Tradition: d.tradition.Name,
+		Remaining: deck,
+		Drawn:     d.cards,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := sessions.Create(s); err != nil {
+		return errorResult(http.StatusInternalServerError, "internal_error", loc.message("session_store_failed"))
+	}
+
+	resp := s.response(s.Drawn, loc, false)
+	resp.Shuffle = d.shuffle
+	return jsonResponse(http.StatusCreated, resp)
+}
+
+func getSessionHandler(id string, loc localizer) (events.APIGatewayV2HTTPResponse, error) {
+	s, err := sessions.Load(id)
+	switch {
+	case errors.Is(err, errSessionNotFound):
+		return errorResult(http.StatusNotFound, "session_not_found", loc.message("session_not_found"))
+	case err != nil:
+		return errorResult(http.StatusInternalServerError, "internal_error", loc.message("session_store_failed"))
+	}
+	return jsonResponse(http.StatusOK, s.response(s.Drawn, loc, false))
+}
+
+func sessionDrawHandler(req events.APIGatewayV2HTTPRequest, id string, loc localizer) (events.APIGatewayV2HTTPResponse, error) {
+	var drawReq sessionDrawRequest
+	if err := decodeRequest(req, &drawReq); err != nil {
+		return decodeErrorResult(loc, err)
+	}
+	if drawReq.Locale != "" {
+		loc = newLocalizer(drawReq.Locale)
+	}
+	numCards := drawReq.NumCards
+	if numCards < 1 {
+		numCards = 1
+	}
+
+	// Take the cards off the top of the stored deck in one update, so that
+	// concurrent draws each get their own cards
+	var drawn []Card
+	clamped := false
+	s, err := sessions.Update(id, func(s *session) error {
+		if len(s.Remaining) == 0 {
+			return errSessionExhausted
+		}
+		if numCards > len(s.Remaining) {
+			numCards = len(s.Remaining)
+			clamped = true
+		}
+		drawn = slices.Clone(s.Remaining[:numCards])
+		s.Remaining = s.Remaining[numCards:]
+		s.Drawn = append(s.Drawn, drawn...)
+		s.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	switch {
+	case errors.Is(err, errSessionNotFound):
+		return errorResult(http.StatusNotFound, "session_not_found", loc.message("session_not_found"))
+	case errors.Is(err, errSessionExhausted):
+		return errorResult(http.StatusConflict, "session_exhausted", loc.message("session_exhausted"))
+	case err != nil:
+		return errorResult(http.StatusInternalServerError, "internal_error", loc.message("session_store_failed"))
+	}
+
+	resp := s.response(drawn, loc, drawReq.IncludeMeanings)
+	if clamped {
+		resp.Message = loc.message("no_more_cards")
+	}
+	return jsonResponse(http.StatusOK, resp)
+}
+
+// response describes s with cards ready to show: image URLs on CloudFront,
+// names in the locale and meanings attached if asked for
+func (s *session) response(cards []Card, loc localizer, includeMeanings bool) sessionResponse {
+	shown := slices.Clone(cards)
+	if shown == nil {
+		shown = []Card{}
+	}
+	for i := range shown {
+		shown[i].Image = cloudFrontURL + "/images/" + shown[i].Image
+	}
+	loc.localizeCards(shown, s.Tradition)
+	if includeMeanings {
+		attachMeanings(shown)
+	}
+
+	return sessionResponse{
+		SessionID:  s.ID,
+		DeckSize:   s.DeckSize,
+		Tradition:  s.Tradition,
+		DrawnCards: shown,
+		Remaining:  len(s.Remaining),
+		Locale:     loc.locale,
+	}
+}
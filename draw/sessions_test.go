@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestSessions_DrawWithoutReplacement(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var created sessionResponse
+	call(t, apiRequest("POST", "/sessions", `{"deckSize": "Major Arcana only", "deckReverse": "Upright and reversed", "significator": "major-09-hermit"}`, nil), 201, &created)
+	if !validSessionID(created.SessionID) || created.Remaining != 21 {
+		t.Fatalf("Expected a new session with 21 cards left, got %+v", created)
+	}
+	if len(created.DrawnCards) != 1 || created.DrawnCards[0].ID != "major-09-hermit" {
+		t.Errorf("Expected the significator to be drawn first, got %+v", created.DrawnCards)
+	}
+
+	path := "/sessions/" + created.SessionID
+	seen := map[string]bool{"major-09-hermit": true}
+	for _, numCards := range []int{1, 5, 14} {
+		var drawn sessionResponse
+		call(t, apiRequest("POST", path+"/draw", `{"numCards": `+strconv.Itoa(numCards)+`}`, nil), 200, &drawn)
+		if len(drawn.DrawnCards) != numCards {
+			t.Fatalf("Expected %d cards, got %d", numCards, len(drawn.DrawnCards))
+		}
+		for _, card := range drawn.DrawnCards {
+			if seen[card.ID] {
+				t.Errorf("Expected each card once, got %s again", card.ID)
+			}
+			seen[card.ID] = true
+		}
+	}
+
+	// Asking for more cards than are left deals the rest
+	var last sessionResponse
+	call(t, apiRequest("POST", path+"/draw", `{"numCards": 3}`, nil), 200, &last)
+	if len(last.DrawnCards) != 1 || last.Remaining != 0 || last.Message == "" {
+		t.Errorf("Expected the last card with a message, got %+v", last)
+	}
+
+	var got sessionResponse
+	call(t, apiRequest("GET", path, "", nil), 200, &got)
+	if len(got.DrawnCards) != 22 || got.Remaining != 0 {
+		t.Errorf("Expected all 22 cards drawn, got %d with %d left", len(got.DrawnCards), got.Remaining)
+	}
+
+	resp, _ := handleRequest(apiRequest("POST", path+"/draw", "", nil))
+	if resp.StatusCode != 409 {
+		t.Errorf("Expected status 409 for an exhausted session, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessions_Errors(t *testing.T) {
+	cases := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{"GET", "/sessions", "", 405, "method_not_allowed"},
+		{"POST", "/sessions", `{"deckSize": "Full Deck"}`, 400, "missing_parameters"},
+		{"POST", "/sessions", `{"deckSize": "Tiny Deck", "deckReverse": "Upright only"}`, 400, "invalid_deck_options"},
+		{"GET", "/sessions/00112233445566778899aabbccddeeff", "", 404, "session_not_found"},
+		{"GET", "/sessions/../../etc/passwd", "", 404, "session_not_found"},
+		{"POST", "/sessions/00112233445566778899aabbccddeeff/draw", "", 404, "session_not_found"},
+		{"POST", "/sessions/00112233445566778899aabbccddeeff", "", 405, "method_not_allowed"},
+	}
+	for _, c := range cases {
+		resp, err := handleRequest(apiRequest(c.method, c.path, c.body, nil))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var errorResp errorResponse
+		if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+			t.Fatalf("Failed to parse error response: %v", err)
+		}
+		if resp.StatusCode != c.status || errorResp.Error != c.code {
+			t.Errorf("%s %s: expected %d %s, got %d %s", c.method, c.path, c.status, c.code, resp.StatusCode, errorResp.Error)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import "errors"
+
+// shuffleOptions selects how the deck is shuffled. Strategy "fisher-yates",
+// the default, is a single uniform shuffle. The physical strategies mimic
+// handling a real deck and are repeated Passes times:
+//
+//   - "riffle" splits the deck in two and riffles the halves together
+//     (Gilbert-Shannon-Reeds model), 7 passes by default
+//   - "overhand" drops small packets from the top onto a new pile, 10 passes
+//     by default
+//   - "piles" deals the deck into Piles piles (3 by default) and gathers
+//     them in a random order, 1 pass by default
+//
+// The physical strategies start from a deck shuffled as by "fisher-yates", as
+// a deck in use is never in its printed order. Run on a deck in file order,
+// a few riffles or a deal into piles would leave most of that order in place
+// and deal much the same reading every time.
+//
+// Cut finishes with a single cut of the deck.
+type shuffleOptions struct {
+	Strategy string `json:"strategy,omitempty"`
+	Passes   int    `json:"passes,omitempty"`
+	Piles    int    `json:"piles,omitempty"`
+	Cut      bool   `json:"cut,omitempty"`
+}
+
+// shuffleStep records one operation applied to the deck. Split is the number
+// of cards in the top half of a riffle or lifted by a cut, Packets the sizes
+// of the packets of an overhand shuffle in the order they were dropped, and
+// Order the piles, counted from 1 in the order dealt, in the order gathered.
+type shuffleStep struct {
+	Operation string `json:"operation"`
+	Split     int    `json:"split,omitempty"`
+	Packets   []int  `json:"packets,omitempty"`
+	Order     []int  `json:"order,omitempty"`
+}
+
+const (
+	shuffleFisherYates = "fisher-yates"
+	shuffleRiffle      = "riffle"
+	shuffleOverhand    = "overhand"
+	shufflePiles       = "piles"
+	shuffleCut         = "cut"
+
+	// maxShufflePasses and maxShufflePiles bound the work a single request
+	// can ask for
+	maxShufflePasses = 100
+	maxShufflePiles  = 12
+)
+
+var errInvalidShuffle = errors.New("invalid shuffle")
+
+// validate checks the strategy is known and the passes and piles are in range
+func (o *shuffleOptions) validate() error {
+	switch o.Strategy {
+	case "", shuffleFisherYates, shuffleRiffle, shuffleOverhand, shufflePiles:
+	default:
+		return errInvalidShuffle
+	}
+	if o.Passes < 0 || o.Passes > maxShufflePasses {
+		return errInvalidShuffle
+	}
+	if o.Piles < 0 || o.Piles > maxShufflePiles || (o.Piles > 0 && o.Strategy != shufflePiles) {
+		return errInvalidShuffle
+	}
+	return nil
+}
+
+// passes returns the number of passes asked for, or the strategy's default
+func (o *shuffleOptions) passes() int {
+	if o.Passes > 0 {
+		return o.Passes
+	}
+	switch o.Strategy {
+	case shuffleRiffle:
+		return 7
+	case shuffleOverhand:
+		return 10
+	default:
+		return 1
+	}
+}
+
+// apply shuffles deck with src as o sets out and returns the shuffled deck
+// and the steps taken
+func (o *shuffleOptions) apply(deck []Card, src RandomSource) ([]Card, []shuffleStep) {
+	var steps []shuffleStep
+	if o.Strategy != "" && o.Strategy != shuffleFisherYates {
+		deck = shuffle(deck, src)
+		steps = append(steps, shuffleStep{Operation: shuffleFisherYates})
+	}
+	for i := 0; i < o.passes(); i++ {
+		var step shuffleStep
+		switch o.Strategy {
+		case shuffleRiffle:
+			deck, step = riffle(deck, src)
+		case shuffleOverhand:
+			deck, step = overhand(deck, src)
+		case shufflePiles:
+			piles := o.Piles
+			if piles == 0 {
+				piles = 3
+			}
+			deck, step = dealPiles(deck, piles, src)
+		default:
+			deck, step = shuffle(deck, src), shuffleStep{Operation: shuffleFisherYates}
+		}
+		steps = append(steps, step)
+	}
+	if o.Cut {
+		var step shuffleStep
+		deck, step = cut(deck, src)
+		steps = append(steps, step)
+	}
+	return deck, steps
+}
+
+// binomialSplit returns the number of heads in n fair coin tosses, which is
+// where a person splitting a deck of n cards "in half" actually splits it
+func binomialSplit(n int, src RandomSource) int {
+	k := 0
+	for i := 0; i < n; i++ {
+		k += uniformInt(src, 2)
+	}
+	return k
+}
+
+// riffle performs one Gilbert-Shannon-Reeds riffle: the deck is split at a
+// binomial point and cards drop from each half with probability proportional
+// to the cards left in it
+func riffle(deck []Card, src RandomSource) ([]Card, shuffleStep) {
+	split := binomialSplit(len(deck), src)
+	top, bottom := deck[:split], deck[split:]
+
+	riffled := make([]Card, 0, len(deck))
+	for len(top) > 0 || len(bottom) > 0 {
+		if uniformInt(src, len(top)+len(bottom)) < len(top) {
+			riffled = append(riffled, top[0])
+			top = top[1:]
+		} else {
+			riffled = append(riffled, bottom[0])
+			bottom = bottom[1:]
+		}
+	}
+	return riffled, shuffleStep{Operation: shuffleRiffle, Split: split}
+}
+
+// overhand performs one overhand shuffle: packets of one to an eighth of the
+// deck are taken from the top in turn and dropped onto a new pile, which
+// reverses their order
+func overhand(deck []Card, src RandomSource) ([]Card, shuffleStep) {
+	maxPacket := max(len(deck)/8, 2)
+	step := shuffleStep{Operation: shuffleOverhand}
+
+	shuffled := make([]Card, len(deck))
+	end := len(deck)
+	for len(deck) > 0 {
+		size := min(1+uniformInt(src, maxPacket), len(deck))
+		copy(shuffled[end-size:end], deck[:size])
+		end -= size
+		deck = deck[size:]
+		step.Packets = append(step.Packets, size)
+	}
+	return shuffled, step
+}
+
+// dealPiles deals the deck one card at a time into piles, each card landing
+// on top of its pile, and gathers the piles in a random order
+func dealPiles(deck []Card, piles int, src RandomSource) ([]Card, shuffleStep) {
+	dealt := make([][]Card, piles)
+	for i, card := range deck {
+		dealt[i%piles] = append([]Card{card}, dealt[i%piles]...)
+	}
+
+	order := make([]int, piles)
+	for i := range order {
+		order[i] = i + 1
+	}
+	for i := piles - 1; i > 0; i-- {
+		j := uniformInt(src, i+1)
+		order[i], order[j] = order[j], order[i]
+	}
+
+	gathered := make([]Card, 0, len(deck))
+	for _, pile := range order {
+		gathered = append(gathered, dealt[pile-1]...)
+	}
+	return gathered, shuffleStep{Operation: shufflePiles, Order: order}
+}
+
+// cut lifts a binomial number of cards off the top and puts them underneath
+func cut(deck []Card, src RandomSource) ([]Card, shuffleStep) {
+	split := binomialSplit(len(deck), src)
+	return append(deck[split:len(deck):len(deck)], deck[:split]...), shuffleStep{Operation: shuffleCut, Split: split}
+}
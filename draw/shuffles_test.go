@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+)
+
+func numberedDeck(n int) []Card {
+	deck := make([]Card, n)
+	for i := range deck {
+		deck[i] = Card{Rank: i}
+	}
+	return deck
+}
+
+func ranks(deck []Card) []int {
+	r := make([]int, len(deck))
+	for i, card := range deck {
+		r[i] = card.Rank
+	}
+	return r
+}
+
+// samePermutation reports whether deck holds each of 0..n-1 exactly once
+func samePermutation(deck []Card, n int) bool {
+	r := ranks(deck)
+	slices.Sort(r)
+	return slices.Equal(r, ranks(numberedDeck(n)))
+}
+
+func TestRiffle_InterleavesHalves(t *testing.T) {
+	src := newPCGSource(16)
+	for i := 0; i < 100; i++ {
+		riffled, step := riffle(numberedDeck(78), src)
+		if step.Operation != shuffleRiffle || !samePermutation(riffled, 78) {
+			t.Fatalf("Expected a riffle of the same cards, got %+v", step)
+		}
+
+		// Each half keeps its own order
+		var top, bottom []int
+		for _, rank := range ranks(riffled) {
+			if rank < step.Split {
+				top = append(top, rank)
+			} else {
+				bottom = append(bottom, rank)
+			}
+		}
+		if !slices.IsSorted(top) || !slices.IsSorted(bottom) || len(top) != step.Split {
+			t.Fatalf("Expected both halves in order, got %v", ranks(riffled))
+		}
+	}
+}
+
+func TestOverhand_ReversesPackets(t *testing.T) {
+	shuffled, step := overhand(numberedDeck(78), newPCGSource(16))
+	if !samePermutation(shuffled, 78) {
+		t.Fatalf("Expected the same cards, got %v", ranks(shuffled))
+	}
+
+	total := 0
+	for _, size := range step.Packets {
+		if size < 1 || size > 9 {
+			t.Errorf("Expected packets of 1 to 9 cards, got %d", size)
+		}
+		total += size
+	}
+	if total != 78 {
+		t.Errorf("Expected packets to add up to 78, got %d", total)
+	}
+
+	// The first packet dropped ends up at the bottom
+	first := step.Packets[0]
+	if want := ranks(numberedDeck(first)); !slices.Equal(ranks(shuffled[78-first:]), want) {
+		t.Errorf("Expected %v at the bottom, got %v", want, ranks(shuffled[78-first:]))
+	}
+}
+
+func TestDealPiles_GathersInOrder(t *testing.T) {
+	gathered, step := dealPiles(numberedDeck(7), 3, newPCGSource(16))
+
+	// Pile 1 is dealt 0, 3, 6 and ends with 6 on top
+	piles := map[int][]int{1: {6, 3, 0}, 2: {4, 1}, 3: {5, 2}}
+	var want []int
+	for _, pile := range step.Order {
+		want = append(want, piles[pile]...)
+	}
+	if !slices.Equal(ranks(gathered), want) {
+		t.Errorf("Expected %v for order %v, got %v", want, step.Order, ranks(gathered))
+	}
+}
+
+func TestCut(t *testing.T) {
+	deck, step := cut(numberedDeck(10), &fixedSource{values: []uint64{1, 1, 1, 0}})
+	if step.Split != 3 {
+		t.Fatalf("Expected a cut of 3 cards, got %d", step.Split)
+	}
+	if want := []int{3, 4, 5, 6, 7, 8, 9, 0, 1, 2}; !slices.Equal(ranks(deck), want) {
+		t.Errorf("Expected %v, got %v", want, ranks(deck))
+	}
+}
+
+func TestShuffleOptions_Apply(t *testing.T) {
+	cases := []struct {
+		opts shuffleOptions
+		want []string
+	}{
+		{shuffleOptions{}, []string{"fisher-yates"}},
+		{shuffleOptions{Strategy: shuffleRiffle}, []string{"fisher-yates", "riffle", "riffle", "riffle", "riffle", "riffle", "riffle", "riffle"}},
+		{shuffleOptions{Strategy: shuffleOverhand, Passes: 2, Cut: true}, []string{"fisher-yates", "overhand", "overhand", "cut"}},
+		{shuffleOptions{Strategy: shufflePiles, Piles: 5}, []string{"fisher-yates", "piles"}},
+	}
+	for _, c := range cases {
+		deck, steps := c.opts.apply(numberedDeck(78), newPCGSource(16))
+		if !samePermutation(deck, 78) {
+			t.Errorf("%+v: expected the same cards", c.opts)
+		}
+		var ops []string
+		for _, step := range steps {
+			ops = append(ops, step.Operation)
+		}
+		if !slices.Equal(ops, c.want) {
+			t.Errorf("%+v: expected %v, got %v", c.opts, c.want, ops)
+		}
+	}
+
+	invalid := []shuffleOptions{
+		{Strategy: "mongean"},
+		{Passes: -1},
+		{Strategy: shuffleRiffle, Passes: maxShufflePasses + 1},
+		{Strategy: shufflePiles, Piles: maxShufflePiles + 1},
+		{Strategy: shuffleRiffle, Piles: 3},
+	}
+	for _, opts := range invalid {
+		if err := opts.validate(); err != errInvalidShuffle {
+			t.Errorf("%+v: expected errInvalidShuffle, got %v", opts, err)
+		}
+	}
+}
+
+func TestShuffleOptions_PositionUniformity(t *testing.T) {
+	// Every strategy, even a single pass, must leave each card equally likely
+	// in every position
+	strategies := []shuffleOptions{
+		{Strategy: shuffleRiffle, Passes: 1},
+		{Strategy: shuffleRiffle},
+		{Strategy: shuffleOverhand},
+		{Strategy: shufflePiles},
+		{Strategy: shufflePiles, Piles: 5, Cut: true},
+	}
+	const size = 22
+	for i, opts := range strategies {
+		src := newPCGSource(uint64(100 + i))
+		chi, df := positionChiSquare(size, 100*size, func(deck []Card) []Card {
+			shuffled, _ := opts.apply(deck, src)
+			return shuffled
+		})
+		if critical := chiSquareCritical(df); chi > critical {
+			t.Errorf("%+v: chi-square %.1f exceeds critical value %.1f (df=%d)", opts, chi, critical, df)
+		}
+	}
+}
+
+func TestDrawHandler_Shuffle(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	body := `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 5, "seed": "riffle",
+		"shuffle": {"strategy": "riffle", "passes": 3, "cut": true}}`
+	var first drawResponse
+	call(t, apiRequest("POST", "/draw", body, nil), 200, &first)
+	if len(first.Shuffle) != 5 || first.Shuffle[0].Operation != "fisher-yates" || first.Shuffle[1].Operation != "riffle" || first.Shuffle[4].Operation != "cut" {
+		t.Fatalf("Expected a shuffled deck, three riffles and a cut, got %+v", first.Shuffle)
+	}
+	var second drawResponse
+	call(t, apiRequest("POST", "/draw", body, nil), 200, &second)
+	for i := range first.DrawnCards {
+		if first.DrawnCards[i].ID != second.DrawnCards[i].ID {
+			t.Errorf("Expected the same seed and shuffle to replay, got %s and %s", first.DrawnCards[i].ID, second.DrawnCards[i].ID)
+		}
+	}
+
+	// Plain draws do not report a shuffle
+	var unshuffled drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only"}`, nil), 200, &unshuffled)
+	if unshuffled.Shuffle != nil {
+		t.Errorf("Expected no shuffle steps, got %+v", unshuffled.Shuffle)
+	}
+
+	resp, err := drawHandler(apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "shuffle": {"strategy": "mongean"}}`, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var errorResp errorResponse
+	if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+		t.Fatalf("Failed to parse error response: %v", err)
+	}
+	if resp.StatusCode != 400 || errorResp.Error != "invalid_shuffle" {
+		t.Errorf("Expected 400 invalid_shuffle, got %d %s", resp.StatusCode, errorResp.Error)
+	}
+}
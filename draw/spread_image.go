@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// drawImageRequest is a draw rendered as one picture of the dealt cards
+type drawImageRequest struct {
+	deckOptions
+	Seed   string `json:"seed,omitempty"`
+	Locale string `json:"locale,omitempty"`
+	// Format is png or jpeg, overriding the Accept header
+	Format string `json:"format,omitempty"`
+	// CardWidth is the width each card is drawn at, in pixels
+	CardWidth int `json:"cardWidth,omitempty"`
+}
+
+// Formats a spread image can be encoded in
+const (
+	imagePNG  = "png"
+	imageJPEG = "jpeg"
+)
+
+// imageMediaTypes maps each image format to the media type it is served as
+var imageMediaTypes = map[string]string{
+	imagePNG:  "image/png",
+	imageJPEG: "image/jpeg",
+}
+
+const (
+	defaultCardWidth = 240
+	minCardWidth     = 60
+	// imageColumns is how many cards a row holds when the draw has no spread
+	imageColumns = 6
+	// maxImageCards, maxImageSide and maxImagePixels bound the canvas, and so
+	// the memory a single image takes, before any of it is allocated
+	maxImageCards  = 24
+	maxImageSide   = 4096
+	maxImagePixels = 8_000_000
+	// maxImageBytes keeps the encoded image within the 6 MB Lambda response
+	// limit once it is base64 encoded
+	maxImageBytes = 4_500_000
+	jpegQuality   = 85
+)
+
+var errImageTooLarge = errors.New("spread image is too large")
+
+var (
+	imageBackground = color.RGBA{0x1e, 0x1b, 0x2e, 0xff}
+	captionColor    = color.RGBA{0xf2, 0xe8, 0xcf, 0xff}
+)
+
+// negotiateImageFormat picks the format of a spread image: format when the
+// request names one, otherwise the image type the Accept value prefers most,
+// and PNG when it prefers neither
+func negotiateImageFormat(format, accept string) (string, error) {
+	if format != "" {
+		format = strings.ToLower(format)
+		if format == "jpg" {
+			format = imageJPEG
+		}
+		if _, ok := imageMediaTypes[format]; !ok {
+			return "", errInvalidFormat
+		}
+		return format, nil
+	}
+
+	for _, mediaRange := range acceptRanges(accept) {
+		switch mediaRange {
+		case "image/jpeg":
+			return imageJPEG, nil
+		case "image/png", "image/*", "*/*":
+			return imagePNG, nil
+		}
+	}
+	return imagePNG, nil
+}
+
+// drawImageHandler handles GET and POST /draw/image, which deal cards as
+// POST /draw does and return them as one PNG or JPEG laid out by spread
+func drawImageHandler(req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	loc := requestLocalizer(req)
+
+	if req.RequestContext.HTTP.Method == "OPTIONS" {
+		return preflight()
+	}
+
+	if req.RequestContext.HTTP.Method != "GET" && req.RequestContext.HTTP.Method != "POST" {
+		return errorResult(http.StatusMethodNotAllowed, "method_not_allowed", loc.message("method_get_or_post"))
+	}
+
+	var imageReq drawImageRequest
+	if err := decodeRequest(req, &imageReq); err != nil {
+		return decodeErrorResult(loc, err)
+	}
+
+	if imageReq.Locale != "" {
+		loc = newLocalizer(imageReq.Locale)
+	}
+
+	if imageReq.DeckSize == "" || imageReq.DeckReverse == "" {
+		return errorResult(http.StatusBadRequest, "missing_parameters", loc.message("missing_deck_options"))
+	}
+
+	format, err := negotiateImageFormat(imageReq.Format, header(req, "Accept"))
+	if err != nil {
+		return errorResult(http.StatusBadRequest, "invalid_format", loc.message("invalid_image_format"))
+	}
+
+	cardWidth := imageReq.CardWidth
+	if cardWidth == 0 {
+		cardWidth = defaultCardWidth
+	}
+	if cardWidth < minCardWidth || cardWidth > maxCardWidth {
+		return errorResult(http.StatusBadRequest, "invalid_request", loc.message("invalid_card_width"))
+	}
+
+	// The same seed deals the same cards as POST /draw, so a reading can be
+	// drawn as JSON first and then as a picture
+	seed := imageReq.Seed
+	if seed == "" {
+		seed = newSeed()
+	}
+	dealt, err := dealCards(imageReq.deckOptions, newSeededSource(seed))
+	if err != nil {
+		return dealErrorResult(loc, err)
+	}
+	if len(dealt.cards) > maxImageCards {
+		return errorResult(http.StatusBadRequest, "image_too_large", loc.message("image_too_large"))
+	}
+	loc.localizeCards(dealt.cards, dealt.tradition.Name)
+
+	img, err := composeReading(dealt.cards, cardWidth)
+	switch {
+	case errors.Is(err, errUnsupportedImage):
+		return errorResult(http.StatusBadRequest, "unsupported_image", loc.message("unsupported_image"))
+	case errors.Is(err, errImageTooLarge):
+		return errorResult(http.StatusBadRequest, "image_too_large", loc.message("image_too_large"))
+	case err != nil:
+		log.Printf("composing spread image: %v", err)
+		return errorResult(http.StatusInternalServerError, "internal_error", loc.message("card_image_failed"))
+	}
+
+	var encoded bytes.Buffer
+	if format == imageJPEG {
+		err = jpeg.Encode(&encoded, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&encoded, img)
+	}
+	if err != nil {
+		return errorResult(http.StatusInternalServerError, "internal_error", loc.message("render_failed"))
+	}
+	if encoded.Len() > maxImageBytes {
+		return errorResult(http.StatusBadRequest, "image_too_large", loc.message("image_too_large"))
+	}
+
+	headers := corsHeaders()
+	headers["Content-Type"] = imageMediaTypes[format]
+	headers["Vary"] = "Accept"
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:      http.StatusOK,
+		Headers:         headers,
+		Body:            base64.StdEncoding.EncodeToString(encoded.Bytes()),
+		IsBase64Encoded: true,
+	}, nil
+}
+
+// cardCell is where a card sits in a spread image, in card pitches, with the
+// card's centre at (x, y)
+type cardCell struct {
+	x, y float64
+}
+
+// cardCells places each card at its spread position, with any significator
+// to the left of the spread's top row. Draws without a spread are laid out
+// in rows of imageColumns cards.
+func cardCells(cards []Card) []cardCell {
+	cells := make([]cardCell, len(cards))
+	inSpread := false
+	minX, minY := math.Inf(1), math.Inf(1)
+	for _, card := range cards {
+		if card.Position != nil && card.Position != significatorPosition {
+			inSpread = true
+			minX, minY = math.Min(minX, card.Position.X), math.Min(minY, card.Position.Y)
+		}
+	}
+
+	for i, card := range cards {
+		switch {
+		case !inSpread:
+			cells[i] = cardCell{float64(i % imageColumns), float64(i / imageColumns)}
+		case card.Position == significatorPosition:
+			cells[i] = cardCell{minX - 1, minY}
+		default:
+			cells[i] = cardCell{card.Position.X, card.Position.Y}
+		}
+	}
+	return cells
+}
+
+// composeReading draws cards onto one image, each cardWidth pixels wide and
+// captioned with its name. Reversed cards are turned 180° on top of any turn
+// their spread position gives them, and cards that share a position, such as
+// the crossing card of a Celtic Cross, are drawn in the order dealt with
+// their captions stacked.
+func composeReading(cards []Card, cardWidth int) (*image.RGBA, error) {
+	faces := make([]*image.RGBA, len(cards))
+	scaled := map[string]*image.RGBA{}
+	cardHeight := 0
+	for i, card := range cards {
+		face, ok := scaled[card.Image]
+		if !ok {
+			src, err := cardImages.get(card.Image)
+			if err != nil {
+				return nil, err
+			}
+			face = scaleImage(src, cardWidth)
+			scaled[card.Image] = face
+		}
+		faces[i] = face
+		cardHeight = max(cardHeight, face.Rect.Dy())
+	}
+
+	// Each cell leaves a gap around the card and room below it for two lines
+	// of caption
+	scale := 1 + cardWidth/240
+	lineHeight := (glyphHeight + 2) * scale
+	gap := cardWidth / 10
+	pitchX := float64(cardWidth + gap)
+	pitchY := float64(cardHeight + gap + 2*lineHeight)
+	maxChars := int(pitchX) / (glyphAdvance * scale)
+
+	type placement struct {
+		face    *image.RGBA
+		at      image.Point
+		caption string
+		textAt  image.Point
+	}
+	placements := make([]placement, len(cards))
+	lines := map[image.Point]int{}
+	var bounds image.Rectangle
+	for i, cell := range cardCells(cards) {
+		card := cards[i]
+		rotation := 0
+		if card.Position != nil {
+			rotation = card.Position.Rotation
+		}
+		if card.Reversed {
+			rotation += 180
+		}
+		face := rotateImage(faces[i], rotation)
+
+		centre := image.Pt(int(math.Round(cell.x*pitchX)), int(math.Round(cell.y*pitchY)))
+		rect := face.Rect.Add(centre.Sub(image.Pt(face.Rect.Dx()/2, face.Rect.Dy()/2)))
+
+		caption := captionText(displayName(card), maxChars)
+		textWidth := captionWidth(caption, scale)
+		textAt := image.Pt(centre.X-textWidth/2, centre.Y+cardHeight/2+2*scale+lines[centre]*lineHeight)
+		lines[centre]++
+
+		placements[i] = placement{face, rect.Min, caption, textAt}
+		bounds = bounds.Union(rect).Union(image.Rectangle{textAt, textAt.Add(image.Pt(textWidth, glyphHeight*scale))})
+	}
+
+	bounds = bounds.Inset(-gap)
+	if bounds.Dx() > maxImageSide || bounds.Dy() > maxImageSide || bounds.Dx()*bounds.Dy() > maxImagePixels {
+		return nil, errImageTooLarge
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Rect, image.NewUniform(imageBackground), image.Point{}, draw.Src)
+	for _, p := range placements {
+		at := p.at.Sub(bounds.Min)
+		draw.Draw(canvas, p.face.Rect.Add(at), p.face, p.face.Rect.Min, draw.Over)
+	}
+	for _, p := range placements {
+		drawCaption(canvas, p.caption, p.textAt.Sub(bounds.Min), scale, captionColor)
+	}
+	return canvas, nil
+}
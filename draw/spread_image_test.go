@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestNegotiateImageFormat(t *testing.T) {
+	cases := []struct {
+		format string
+		accept string
+		want   string
+	}{
+		{"", "", imagePNG},
+		{"", "*/*", imagePNG},
+		{"", "image/jpeg", imageJPEG},
+		{"", "image/png;q=0.5, image/jpeg", imageJPEG},
+		{"", "image/*", imagePNG},
+		{"", "text/html", imagePNG},
+		{"JPG", "image/png", imageJPEG},
+		{"png", "image/jpeg", imagePNG},
+	}
+	for _, c := range cases {
+		got, err := negotiateImageFormat(c.format, c.accept)
+		if err != nil || got != c.want {
+			t.Errorf("format %q, Accept %q: expected %s, got %s (%v)", c.format, c.accept, c.want, got, err)
+		}
+	}
+
+	if _, err := negotiateImageFormat("gif", ""); err != errInvalidFormat {
+		t.Errorf("Expected errInvalidFormat, got %v", err)
+	}
+}
+
+// drawImage calls the handler and decodes the image it returns
+func drawImage(t *testing.T, req events.APIGatewayV2HTTPRequest) (image.Image, events.APIGatewayV2HTTPResponse) {
+	t.Helper()
+	resp, err := handleRequest(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 200 || !resp.IsBase64Encoded {
+		t.Fatalf("Expected a base64 encoded 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode image: %v", err)
+	}
+	if "image/"+format != resp.Headers["Content-Type"] {
+		t.Errorf("Expected a %s body, got %s", resp.Headers["Content-Type"], format)
+	}
+	return img, resp
+}
+
+func TestDrawImageHandler_Spread(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+	useTestCardImages(t)
+
+	// Cards 100 wide are drawn 10 apart with a 10 pixel margin, so the first
+	// card's top left corner is at 10,10 and each caption line is 10 high
+	upright, _ := drawImage(t, apiRequest("POST", "/draw/image", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "spread": "three-card", "cardWidth": 100, "seed": "image"}`, nil))
+	if upright.Bounds() != image.Rect(0, 0, 340, 190) {
+		t.Fatalf("Expected a 340x190 image, got %v", upright.Bounds())
+	}
+	reversed, _ := drawImage(t, apiRequest("POST", "/draw/image", `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "reversal": {"probability": 1}, "spread": "three-card", "cardWidth": 100, "seed": "image"}`, nil))
+
+	for i := 0; i < 3; i++ {
+		top := image.Pt(10+i*110+50, 15)
+		if colorAt(upright, top) != testTop || colorAt(reversed, top) != testBottom {
+			t.Errorf("Card %d: expected the upright card red at the top and the reversed card turned, got %v and %v", i+1, colorAt(upright, top), colorAt(reversed, top))
+		}
+		// Each card is captioned with its name below it
+		caption := 0
+		for y := 172; y < 180; y++ {
+			for x := 10 + i*110; x < 110+i*110; x++ {
+				if colorAt(upright, image.Pt(x, y)) == captionColor {
+					caption++
+				}
+			}
+		}
+		if caption == 0 {
+			t.Errorf("Card %d: expected a caption below the card", i+1)
+		}
+	}
+}
+
+func colorAt(img image.Image, p image.Point) any {
+	return image.NewRGBA(image.Rect(0, 0, 1, 1)).ColorModel().Convert(img.At(p.X, p.Y))
+}
+
+func TestCardCells(t *testing.T) {
+	deck, _, _ := getDeck("Full Deck", "Upright only", "", nil, nil, nil)
+	cards := deck[:8]
+	cells := cardCells(cards)
+	if cells[7] != (cardCell{1, 1}) {
+		t.Errorf("Expected the eighth card of a plain draw in the second row, got %v", cells[7])
+	}
+
+	cards[0].Position = significatorPosition
+	cards[1].Position = &spreadPosition{Index: 1, X: 2, Y: 1}
+	cards[2].Position = &spreadPosition{Index: 2, X: 3.5, Y: 0.5, Rotation: 90}
+	cells = cardCells(cards[:3])
+	want := []cardCell{{1, 0.5}, {2, 1}, {3.5, 0.5}}
+	for i := range want {
+		if cells[i] != want[i] {
+			t.Errorf("Card %d: expected %v, got %v", i+1, want[i], cells[i])
+		}
+	}
+}
+
+func TestDrawImageHandler_JPEGFromQuery(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+	source := useTestCardImages(t)
+
+	req := apiRequest("GET", "/draw/image", "", map[string]string{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "numCards": "8", "cardWidth": "60", "locale": "fr", "seed": "query"})
+	req.Headers = map[string]string{"Accept": "image/jpeg"}
+	img, resp := drawImage(t, req)
+	if resp.Headers["Content-Type"] != "image/jpeg" || resp.Headers["Vary"] != "Accept" {
+		t.Errorf("Expected a JPEG varying on Accept, got %v", resp.Headers)
+	}
+	// Eight cards 60x96 without a spread fill a row of six and start a
+	// second; captions may be a little wider than the cards
+	if img.Bounds().Dx() < 402 || img.Bounds().Dx() > 414 || img.Bounds().Dy() != 240 {
+		t.Errorf("Expected two rows of six 66x122 cells, got %v", img.Bounds())
+	}
+
+	// A second draw of the same cards decodes nothing new
+	opens := len(source.opens)
+	drawImage(t, req)
+	for name, n := range source.opens {
+		if n != 1 {
+			t.Errorf("Expected %s to be decoded once, got %d", name, n)
+		}
+	}
+	if len(source.opens) != opens {
+		t.Errorf("Expected %d images decoded, got %d", opens, len(source.opens))
+	}
+}
+
+func TestDrawImageHandler_Errors(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+	useTestCardImages(t)
+
+	cases := []struct {
+		method string
+		body   string
+		status int
+		code   string
+	}{
+		{"PUT", "", 405, "method_not_allowed"},
+		{"POST", `{"deckReverse": "Upright only"}`, 400, "missing_parameters"},
+		{"POST", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "format": "gif"}`, 400, "invalid_format"},
+		{"POST", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "cardWidth": 1000}`, 400, "invalid_request"},
+		{"POST", `{"deckSize": "Tiny Deck", "deckReverse": "Upright only"}`, 400, "invalid_deck_options"},
+		{"POST", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 25}`, 400, "image_too_large"},
+		{"POST", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "customSpread": {"name": "wide", "positions": [{"name": "a"}, {"name": "b", "x": 40}]}}`, 400, "image_too_large"},
+		{"POST", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "customSpread": {"name": "far", "positions": [{"name": "a", "x": 1e300}]}}`, 400, "invalid_spread"},
+	}
+	for _, c := range cases {
+		req := apiRequest("POST", "/draw/image", c.body, nil)
+		req.RequestContext.HTTP.Method = c.method
+		resp, err := handleRequest(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp.StatusCode != c.status || resp.Headers["Content-Type"] != "application/json" || !strings.Contains(resp.Body, `"`+c.code+`"`) {
+			t.Errorf("%s %s: expected a JSON %d %s, got %d: %s", c.method, c.body, c.status, c.code, resp.StatusCode, resp.Body)
+		}
+	}
+}
+
+func TestDrawImageHandler_Assets(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+	saved := cardImages
+	cardImages = newCardImageCache(dirImageSource("../assets/images"))
+	t.Cleanup(func() { cardImages = saved })
+
+	// Three cards 240 wide, 24 apart, with a 24 pixel margin, from each deck
+	for _, deck := range []string{"Major Arcana only", "Petit Lenormand", "52-card deck"} {
+		img, _ := drawImage(t, apiRequest("POST", "/draw/image", `{"deckSize": "`+deck+`", "deckReverse": "Upright and reversed", "spread": "three-card", "format": "png", "seed": "assets"}`, nil))
+		if img.Bounds().Dx() != 816 || img.Bounds().Dy() < 415 {
+			t.Errorf("%s: expected an 816 pixel wide row of three cards, got %v", deck, img.Bounds())
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// spread is a named layout whose positions are filled in order from the
+// shuffled deck
+type spread struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Positions   []*spreadPosition `json:"positions"`
+}
+
+// spreadPosition is one place in a spread. Index counts from 1 in the order
+// cards are dealt. X and Y place the centre of the card on a grid one card
+// wide and one card tall, with Y growing downwards; Rotation is in degrees
+// clockwise, so the crossing card of a Celtic Cross has 90. Rule, if set,
+// limits which cards the position accepts.
+type spreadPosition struct {
+	Index       int         `json:"index"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	X           float64     `json:"x"`
+	Y           float64     `json:"y"`
+	Rotation    int         `json:"rotation"`
+	Rule        *spreadRule `json:"rule,omitempty"`
+}
+
+// spreadRule limits a position to cards of one arcana and/or of some suits,
+// e.g. {"arcana": "major"} for majors only
+type spreadRule struct {
+	Arcana Arcana `json:"arcana,omitempty"`
+	Suits  []Suit `json:"suits,omitempty"`
+}
+
+const (
+	// maxSpreadPositions bounds a custom spread at the size of the largest deck
+	maxSpreadPositions = 78
+	// maxSpreadCoordinate bounds a position's x and y, in card widths and
+	// heights, so a layout stays small enough to draw
+	maxSpreadCoordinate = 100
+)
+
+var (
+	errUnknownSpread  = errors.New("unknown spread")
+	errInvalidSpread  = errors.New("invalid custom spread")
+	errSpreadConflict = errors.New("spread cannot be combined with customSpread")
+	errSpreadTooLarge = errors.New("not enough cards to fill the spread")
+)
+
+//go:embed data/spreads.json
+var spreadsJSON []byte
+
+// spreads holds the spread catalog in the order it is published
+var spreads = loadSpreads()
+
+func loadSpreads() []*spread {
+	var s []*spread
+	if err := json.Unmarshal(spreadsJSON, &s); err != nil {
+		panic("invalid data/spreads.json: " + err.Error())
+	}
+	if err := validateSpreads(s); err != nil {
+		panic("invalid data/spreads.json: " + err.Error())
+	}
+	for _, sp := range s {
+		sp.number()
+	}
+	return s
+}
+
+// validateSpreads checks that every spread has a unique ID and is valid
+func validateSpreads(s []*spread) error {
+	seen := map[string]bool{}
+	for _, sp := range s {
+		if sp.ID == "" {
+			return errors.New("spread without an id")
+		}
+		if seen[sp.ID] {
+			return fmt.Errorf("duplicate spread %q", sp.ID)
+		}
+		seen[sp.ID] = true
+		if err := sp.validate(); err != nil {
+			return fmt.Errorf("spread %q: %w", sp.ID, err)
+		}
+	}
+	return nil
+}
+
+// validate checks that the spread has between 1 and maxSpreadPositions named
+// positions, with coordinates from 0 to maxSpreadCoordinate, rotations within
+// a turn and rules naming a known arcana or suit
+func (s *spread) validate() error {
+	if len(s.Positions) == 0 || len(s.Positions) > maxSpreadPositions {
+		return fmt.Errorf("expected 1 to %d positions, got %d", maxSpreadPositions, len(s.Positions))
+	}
+	for i, pos := range s.Positions {
+		if pos == nil || pos.Name == "" {
+			return fmt.Errorf("position %d has no name", i+1)
+		}
+		if pos.X < 0 || pos.X > maxSpreadCoordinate || pos.Y < 0 || pos.Y > maxSpreadCoordinate {
+			return fmt.Errorf("position %d is at %v,%v", i+1, pos.X, pos.Y)
+		}
+		if pos.Rotation < 0 || pos.Rotation >= 360 {
+			return fmt.Errorf("position %d has rotation %d", i+1, pos.Rotation)
+		}
+		if pos.Rule == nil {
+			continue
+		}
+		if pos.Rule.Arcana != "" && pos.Rule.Arcana != ArcanaMajor && pos.Rule.Arcana != ArcanaMinor {
+			return fmt.Errorf("position %d has unknown arcana %q", i+1, pos.Rule.Arcana)
+		}
+		for _, suit := range pos.Rule.Suits {
+			if !knownSuit(suit) {
+				return fmt.Errorf("position %d has unknown suit %q", i+1, suit)
+			}
+		}
+	}
+	return nil
+}
+
+// knownSuit reports whether any card set has cards of suit
+func knownSuit(suit Suit) bool {
+	for _, set := range cardSets {
+		for _, def := range set.Cards {
+			if suit != SuitNone && def.Suit == suit {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// number sets the index of each position from its order in the spread
+func (s *spread) number() {
+	for i, pos := range s.Positions {
+		pos.Index = i + 1
+	}
+}
+
+// lookupSpread returns the spread with the given ID
+func lookupSpread(id string) (*spread, error) {
+	for _, sp := range spreads {
+		if sp.ID == id {
+			return sp, nil
+		}
+	}
+	return nil, errUnknownSpread
+}
+
+// resolveSpread returns the catalog spread with the given ID or the client's
+// custom spread, or nil when the request names neither
+func resolveSpread(id string, custom *spread) (*spread, error) {
+	switch {
+	case id != "" && custom != nil:
+		return nil, errSpreadConflict
+	case id != "":
+		return lookupSpread(id)
+	case custom == nil:
+		return nil, nil
+	}
+
+	if err := custom.validate(); err != nil {
+		return nil, errInvalidSpread
+	}
+	if custom.ID == "" {
+		custom.ID = "custom"
+	}
+	custom.number()
+	return custom, nil
+}
+
+// accepts reports whether card meets the rule
+func (r *spreadRule) accepts(card Card) bool {
+	if r == nil {
+		return true
+	}
+	if r.Arcana != "" && card.Arcana != r.Arcana {
+		return false
+	}
+	return len(r.Suits) == 0 || slices.Contains(r.Suits, card.Suit)
+}
+
+// deal fills each position in turn with the first card left in the shuffled
+// deck that meets its rule, and annotates the card with that position. It
+// also returns the cards left over, in their shuffled order.
+func (s *spread) deal(deck []Card) ([]Card, []Card, error) {
+	dealt := make([]Card, 0, len(s.Positions))
+	used := make([]bool, len(deck))
+	for _, pos := range s.Positions {
+		i := 0
+		for i < len(deck) && (used[i] || !pos.Rule.accepts(deck[i])) {
+			i++
+		}
+		if i == len(deck) {
+			return nil, nil, errSpreadTooLarge
+		}
+		used[i] = true
+		card := deck[i]
+		card.Position = pos
+		dealt = append(dealt, card)
+	}
+
+	rest := make([]Card, 0, len(deck)-len(dealt))
+	for i, card := range deck {
+		if !used[i] {
+			rest = append(rest, card)
+		}
+	}
+	return dealt, rest, nil
+}
+
+// spreadErrorResult builds the error response for a resolveSpread or deal error
+func spreadErrorResult(loc localizer, err error) (events.APIGatewayV2HTTPResponse, error) {
+	switch err {
+	case errSpreadConflict:
+		return errorResult(http.StatusBadRequest, "invalid_request", loc.message("spread_with_custom"))
+	case errInvalidSpread:
+		return errorResult(http.StatusBadRequest, "invalid_spread", loc.message("invalid_custom_spread"))
+	case errSpreadTooLarge:
+		return errorResult(http.StatusBadRequest, "spread_too_large", loc.message("spread_too_large"))
+	default:
+		return errorResult(http.StatusBadRequest, "invalid_spread", loc.message("invalid_spread"))
+	}
+}
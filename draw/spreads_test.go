@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestSpreads_Catalog(t *testing.T) {
+	want := map[string]int{
+		"single":        1,
+		"three-card":    3,
+		"celtic-cross":  10,
+		"horseshoe":     7,
+		"relationship":  6,
+		"twelve-houses": 12,
+	}
+
+	for id, size := range want {
+		sp, err := lookupSpread(id)
+		if err != nil {
+			t.Errorf("Expected spread %s, got %v", id, err)
+			continue
+		}
+		if len(sp.Positions) != size {
+			t.Errorf("%s: expected %d positions, got %d", id, size, len(sp.Positions))
+		}
+		for i, pos := range sp.Positions {
+			if pos.Index != i+1 || pos.Description == "" {
+				t.Errorf("%s: unexpected position %+v at %d", id, pos, i)
+			}
+		}
+	}
+
+	if _, err := lookupSpread("tree-of-life"); err != errUnknownSpread {
+		t.Errorf("Expected errUnknownSpread, got %v", err)
+	}
+}
+
+func TestSpreads_CelticCrossCrossingCard(t *testing.T) {
+	sp, _ := lookupSpread("celtic-cross")
+	present, challenge := sp.Positions[0], sp.Positions[1]
+	if challenge.Name != "Challenge" || challenge.Rotation != 90 {
+		t.Errorf("Expected the Challenge turned 90 degrees, got %+v", challenge)
+	}
+	if challenge.X != present.X || challenge.Y != present.Y || present.Rotation != 0 {
+		t.Errorf("Expected the Challenge to lie across the Present, got %+v and %+v", present, challenge)
+	}
+}
+
+func TestValidateSpreads(t *testing.T) {
+	cases := map[string][]*spread{
+		"missing id":       {{Positions: []*spreadPosition{{Name: "Card"}}}},
+		"duplicate id":     {{ID: "a", Positions: []*spreadPosition{{Name: "Card"}}}, {ID: "a", Positions: []*spreadPosition{{Name: "Card"}}}},
+		"no positions":     {{ID: "a"}},
+		"unnamed position": {{ID: "a", Positions: []*spreadPosition{{Description: "Card"}}}},
+	}
+	for name, s := range cases {
+		if err := validateSpreads(s); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDrawHandler_Spread(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	// The spread decides how many cards are dealt
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 3, "spread": "celtic-cross"}`, nil), 200, &drawResp)
+	if drawResp.Spread != "celtic-cross" {
+		t.Errorf("Expected spread 'celtic-cross', got '%s'", drawResp.Spread)
+	}
+	if len(drawResp.DrawnCards) != 10 {
+		t.Fatalf("Expected 10 cards, got %d", len(drawResp.DrawnCards))
+	}
+	for i, card := range drawResp.DrawnCards {
+		if card.Position == nil || card.Position.Index != i+1 {
+			t.Errorf("Expected position %d for card %d, got %+v", i+1, i, card.Position)
+		}
+	}
+	if crossing := drawResp.DrawnCards[1].Position; crossing.Name != "Challenge" || crossing.Rotation != 90 {
+		t.Errorf("Expected the second card to cross the first, got %+v", crossing)
+	}
+
+	// Plain draws carry no positions
+	drawResp = drawResponse{}
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "numCards": 3}`, nil), 200, &drawResp)
+	if drawResp.Spread != "" || drawResp.DrawnCards[0].Position != nil {
+		t.Errorf("Expected no spread, got '%s' and %+v", drawResp.Spread, drawResp.DrawnCards[0].Position)
+	}
+
+	resp, err := drawHandler(apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "spread": "tree-of-life"}`, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("Expected status 400, got %d", resp.StatusCode)
+	}
+
+	var errorResp errorResponse
+	if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+		t.Fatalf("Failed to parse error response: %v", err)
+	}
+	if errorResp.Error != "invalid_spread" {
+		t.Errorf("Expected error 'invalid_spread', got '%s'", errorResp.Error)
+	}
+}
+
+func TestResolveSpread_Custom(t *testing.T) {
+	custom := &spread{Positions: []*spreadPosition{
+		{Name: "Situation", X: 0, Y: 0},
+		{Name: "Lesson", X: 1, Y: 0, Rule: &spreadRule{Arcana: ArcanaMajor}},
+		{Name: "Feelings", X: 2, Y: 0, Rule: &spreadRule{Suits: []Suit{SuitCups}}},
+	}}
+
+	sp, err := resolveSpread("", custom)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sp.ID != "custom" || sp.Positions[2].Index != 3 {
+		t.Errorf("Expected a numbered custom spread, got %+v", sp)
+	}
+
+	if _, err := resolveSpread("single", custom); err != errSpreadConflict {
+		t.Errorf("Expected errSpreadConflict, got %v", err)
+	}
+	if sp, err := resolveSpread("", nil); sp != nil || err != nil {
+		t.Errorf("Expected no spread, got %+v and %v", sp, err)
+	}
+
+	invalid := map[string]*spread{
+		"no positions":   {},
+		"unnamed":        {Positions: []*spreadPosition{{X: 1}}},
+		"rotation":       {Positions: []*spreadPosition{{Name: "Card", Rotation: 360}}},
+		"unknown arcana": {Positions: []*spreadPosition{{Name: "Card", Rule: &spreadRule{Arcana: "trumps"}}}},
+		"unknown suit":   {Positions: []*spreadPosition{{Name: "Card", Rule: &spreadRule{Suits: []Suit{"stars"}}}}},
+		"too many":       {Positions: make([]*spreadPosition, maxSpreadPositions+1)},
+	}
+	for name, sp := range invalid {
+		if _, err := resolveSpread("", sp); err != errInvalidSpread {
+			t.Errorf("%s: expected errInvalidSpread, got %v", name, err)
+		}
+	}
+}
+
+func TestSpreadDeal_FollowsRules(t *testing.T) {
+	deck := []Card{
+		{ID: "cups-01", Arcana: ArcanaMinor, Suit: SuitCups},
+		{ID: "wands-01", Arcana: ArcanaMinor, Suit: SuitWands},
+		{ID: "major-00-fool", Arcana: ArcanaMajor},
+		{ID: "cups-02", Arcana: ArcanaMinor, Suit: SuitCups},
+	}
+	sp := &spread{Positions: []*spreadPosition{
+		{Name: "Major", Rule: &spreadRule{Arcana: ArcanaMajor}},
+		{Name: "Any"},
+		{Name: "Wands", Rule: &spreadRule{Suits: []Suit{SuitWands}}},
+	}}
+
+	dealt, rest, err := sp.deal(deck)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []string{"major-00-fool", "cups-01", "wands-01"}
+	for i, card := range dealt {
+		if card.ID != want[i] || card.Position != sp.Positions[i] {
+			t.Errorf("Expected %s in position %d, got %s", want[i], i+1, card.ID)
+		}
+	}
+	if len(rest) != 1 || rest[0].ID != "cups-02" {
+		t.Errorf("Expected cups-02 left over, got %+v", rest)
+	}
+
+	sp.Positions = append(sp.Positions, &spreadPosition{Name: "Another major", Rule: &spreadRule{Arcana: ArcanaMajor}})
+	if _, _, err := sp.deal(deck); err != errSpreadTooLarge {
+		t.Errorf("Expected errSpreadTooLarge, got %v", err)
+	}
+}
+
+func TestDrawHandler_CustomSpread(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	body := `{"deckSize": "Full Deck", "deckReverse": "Upright and reversed", "seed": "custom", "customSpread": {
+		"name": "Path",
+		"positions": [
+			{"name": "Where you are"},
+			{"name": "Lesson", "x": 1, "rule": {"arcana": "major"}},
+			{"name": "Lesson", "x": 2, "rule": {"arcana": "major"}},
+			{"name": "Feelings", "x": 3, "rotation": 45, "rule": {"suits": ["cups"]}}
+		]}}`
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", body, nil), 200, &drawResp)
+	if drawResp.Spread != "custom" || len(drawResp.DrawnCards) != 4 {
+		t.Fatalf("Expected 4 cards in a custom spread, got %d in '%s'", len(drawResp.DrawnCards), drawResp.Spread)
+	}
+	for _, card := range drawResp.DrawnCards[1:3] {
+		if card.Arcana != ArcanaMajor {
+			t.Errorf("Expected a major arcana card for %s, got %s", card.Position.Name, card.ID)
+		}
+	}
+	if feelings := drawResp.DrawnCards[3]; feelings.Suit != SuitCups || feelings.Position.Rotation != 45 {
+		t.Errorf("Expected a cup turned 45 degrees, got %s at %d", feelings.ID, feelings.Position.Rotation)
+	}
+
+	cases := map[string]string{
+		`{"deckSize": "Full Deck", "deckReverse": "Upright only", "customSpread": {"positions": []}}`:                                                      "invalid_spread",
+		`{"deckSize": "Full Deck", "deckReverse": "Upright only", "spread": "single", "customSpread": {"positions": [{"name": "Card"}]}}`:                  "invalid_request",
+		`{"deckSize": "Minor Arcana only", "deckReverse": "Upright only", "customSpread": {"positions": [{"name": "Card", "rule": {"arcana": "major"}}]}}`: "spread_too_large",
+		`{"deckSize": "Full Deck", "deckReverse": "Upright only", "customSpread": {"positions": [{"name": "Card", "x": 1e300}]}}`:                          "invalid_spread",
+		`{"deckSize": "Full Deck", "deckReverse": "Upright only", "customSpread": {"positions": [{"name": "Card", "y": -1}]}}`:                             "invalid_spread",
+	}
+	for body, code := range cases {
+		resp, err := drawHandler(apiRequest("POST", "/draw", body, nil))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var errorResp errorResponse
+		if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+			t.Fatalf("Failed to parse error response: %v", err)
+		}
+		if resp.StatusCode != 400 || errorResp.Error != code {
+			t.Errorf("Expected 400 %s, got %d %s", code, resp.StatusCode, errorResp.Error)
+		}
+	}
+}
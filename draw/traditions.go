@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// tradition names and numbers the cards of a card set the way one school of
+// reading does. Cards keep their ID and image whatever a tradition calls them,
+// so the picture always matches the name shown.
+type tradition struct {
+	Name string `json:"-"`
+	// Titles holds the names of the unsuited cards by card ID
+	Titles map[string]string `json:"titles"`
+	// Numbers holds the unsuited cards whose number differs from their rank
+	Numbers map[string]int `json:"numbers"`
+	// Ranks holds the names of the suited cards' ranks, keyed by rank
+	Ranks map[string]string `json:"ranks"`
+	Suits map[Suit]string   `json:"suits"`
+}
+
+// lookupTradition returns the named tradition of set, or the set's default
+// tradition when name is empty
+func (s *cardSet) lookupTradition(name string) (*tradition, bool) {
+	if name == "" {
+		name = s.DefaultTradition
+	}
+	trad, ok := s.Traditions[strings.ToLower(name)]
+	return trad, ok
+}
+
+// number returns the number trad gives the card def
+func (t *tradition) number(def cardDefinition) int {
+	if number, ok := t.Numbers[def.ID]; ok {
+		return number
+	}
+	return def.Rank
+}
+
+// rankName returns the name trad gives a suited card's rank
+func (t *tradition) rankName(rank int) string {
+	return t.Ranks[strconv.Itoa(rank)]
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTraditions_ImagesMatchNames(t *testing.T) {
+	for name := range cardSets["tarot"].Traditions {
+		seen := map[int]bool{}
+		for position, card := range tarotDeck("tarot-major", name) {
+			if card.Rank != position || card.Number != cardSets["tarot"].Numerals[position] {
+				t.Errorf("%s: card %s at position %d has number %s", name, card.ID, position, card.Number)
+			}
+			seen[card.Rank] = true
+
+			// RWS_Tarot_08_Strength.jpg belongs to major-08-strength
+			if !strings.HasPrefix(card.Image, "RWS_Tarot_"+card.ID[len("major-"):len("major-00")]+"_") {
+				t.Errorf("%s: %s (%s) shows image %s", name, card.ID, card.NameSuit, card.Image)
+			}
+		}
+		if len(seen) != 22 {
+			t.Errorf("%s: expected 22 distinct numbers, got %d", name, len(seen))
+		}
+
+		// Cups14.jpg belongs to cups-14; Pents is the one abbreviated suit
+		for _, card := range tarotDeck("tarot-minor", name) {
+			prefix := strings.ToUpper(card.ID[:1]) + card.ID[1:]
+			prefix = strings.Replace(strings.Replace(prefix, "Pentacles", "Pents", 1), "-", "", 1)
+			if card.Image != prefix+".jpg" && card.ID != "wands-09" {
+				t.Errorf("%s: %s shows image %s", name, card.ID, card.Image)
+			}
+		}
+	}
+}
+
+func TestTraditions_StrengthAndJustice(t *testing.T) {
+	cases := []struct {
+		tradition string
+		viii      string
+		xi        string
+	}{
+		{"rws", "Strength", "Justice"},
+		{"marseille", "Justice", "Strength"},
+		{"thoth", "Adjustment", "Lust"},
+	}
+
+	for _, c := range cases {
+		deck := tarotDeck("tarot-major", c.tradition)
+		if deck[8].NameSuit != c.viii || deck[11].NameSuit != c.xi {
+			t.Errorf("%s: expected %s at VIII and %s at XI, got %s and %s",
+				c.tradition, c.viii, c.xi, deck[8].NameSuit, deck[11].NameSuit)
+		}
+	}
+
+	if got := tarotDeck("tarot-major", "marseille")[2].NameSuit; got != "The Papess" {
+		t.Errorf("Expected The Papess in the Marseille deck, got %s", got)
+	}
+	if got := tarotDeck("tarot-major", "rws")[2].NameSuit; got != "The High Priestess" {
+		t.Errorf("Expected The High Priestess in the RWS deck, got %s", got)
+	}
+}
+
+func TestTraditions_ThothCourts(t *testing.T) {
+	names := map[int]string{}
+	for _, card := range tarotDeck("tarot-minor", "thoth") {
+		if card.Suit == SuitCups {
+			names[card.Rank] = card.Number + " " + card.NameSuit
+		}
+	}
+
+	want := map[int]string{11: "Princess of Cups", 12: "Prince of Cups", 13: "Queen of Cups", 14: "Knight of Cups"}
+	for rank, name := range want {
+		if names[rank] != name {
+			t.Errorf("Expected %s at rank %d, got %s", name, rank, names[rank])
+		}
+	}
+}
+
+func TestDrawHandler_Tradition(t *testing.T) {
+	os.Setenv("CLOUDFRONT_URL", "https://test.cloudfront.net")
+
+	var drawResp drawResponse
+	call(t, apiRequest("POST", "/draw", `{"deckSize": "Major Arcana only", "deckReverse": "Upright only", "numCards": 22, "tradition": "Thoth"}`, nil), 200, &drawResp)
+	if drawResp.Tradition != "thoth" {
+		t.Errorf("Expected tradition 'thoth', got '%s'", drawResp.Tradition)
+	}
+	for _, card := range drawResp.DrawnCards {
+		if card.NameSuit != tarotTradition("thoth").Titles[card.ID] {
+			t.Errorf("Expected Thoth title for %s, got '%s'", card.ID, card.NameSuit)
+		}
+	}
+
+	resp, err := drawHandler(apiRequest("POST", "/draw", `{"deckSize": "Full Deck", "deckReverse": "Upright only", "tradition": "visconti"}`, nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("Expected status 400, got %d", resp.StatusCode)
+	}
+
+	var errorResp errorResponse
+	if err := json.Unmarshal([]byte(resp.Body), &errorResp); err != nil {
+		t.Fatalf("Failed to parse error response: %v", err)
+	}
+	if errorResp.Error != "invalid_tradition" {
+		t.Errorf("Expected error 'invalid_tradition', got '%s'", errorResp.Error)
+	}
+}
@@ -9,7 +9,7 @@ module "api_gateway" {
   cors_configuration = {
     allow_credentials = false
     allow_headers     = ["content-type", "authorization"]
-    allow_methods     = ["POST", "OPTIONS"]
+    allow_methods     = ["GET", "POST", "OPTIONS"]
     allow_origins     = ["https://${var.frontend_domain_name}"]
     expose_headers    = ["date"]
     max_age           = 86400
@@ -57,6 +57,57 @@ locals {
       route_key  = "POST /draw"
       lambda_key = "draw"
     }
+    draw_commit = {
+      route_key  = "POST /draw/commit"
+      lambda_key = "draw"
+    }
+    draw_verify = {
+      route_key  = "POST /draw/verify"
+      lambda_key = "draw"
+    }
+    draw_clarify = {
+      route_key  = "POST /draw/clarify"
+      lambda_key = "draw"
+    }
+    draw_image_get = {
+      route_key  = "GET /draw/image"
+      lambda_key = "draw"
+    }
+    draw_image = {
+      route_key  = "POST /draw/image"
+      lambda_key = "draw"
+    }
+    keys = {
+      route_key  = "GET /keys"
+      lambda_key = "draw"
+    }
+    daily = {
+      route_key  = "GET /daily"
+      lambda_key = "draw"
+    }
+    cards = {
+      route_key  = "GET /cards"
+      lambda_key = "draw"
+    }
+    card = {
+      route_key  = "GET /cards/{id}"
+      lambda_key = "draw"
+    }
+    decks = {
+      route_key  = "GET /decks"
+      lambda_key = "draw"
+    }
+    spreads = {
+      route_key  = "GET /spreads"
+      lambda_key = "draw"
+    }
+    openapi = {
+      route_key  = "GET /openapi.json"
+      lambda_key = "draw"
+    }
+    # The /sessions routes are left out until sessions have a store shared by
+    # every Lambda container; in memory, a session only lives in the container
+    # that opened it
   }
 }
 
@@ -61,7 +61,9 @@ module "lambda_functions" {
   memory_size   = var.lambda_memory_size
 
   environment_variables = {
-    CLOUDFRONT_URL = "https://${aws_cloudfront_distribution.tarot_distribution.domain_name}"
+    CLOUDFRONT_URL      = "https://${aws_cloudfront_distribution.tarot_distribution.domain_name}"
+    DRAW_SECRET         = var.draw_secret
+    RECEIPT_SIGNING_KEY = var.receipt_signing_key
   }
 
   create_role                       = false
@@ -40,8 +40,9 @@ resource "aws_s3_bucket_policy" "tarot_images_policy" {
 resource "aws_s3_object" "card" {
   for_each = toset(fileset("${path.module}/../assets/images", "*"))
 
-  bucket = aws_s3_bucket.tarot_images.id
-  key    = "images/${each.value}"
-  source = "${path.module}/../assets/images/${each.value}"
-  etag   = filemd5("${path.module}/../assets/images/${each.value}")
+  bucket       = aws_s3_bucket.tarot_images.id
+  key          = "images/${each.value}"
+  source       = "${path.module}/../assets/images/${each.value}"
+  etag         = filemd5("${path.module}/../assets/images/${each.value}")
+  content_type = lookup(local.mime_types, lower(regex("\\.[^.]+$", each.value)), "application/octet-stream")
 }
@@ -33,6 +33,17 @@ variable "default_throttling_rate_limit" {
 
 variable "domain_name" {}
 
+variable "draw_secret" {
+  description = "Secret key, e.g. from openssl rand -hex 32, that every Lambda container derives commit-reveal server seeds, deck-state token keys and the card of the day from"
+  type        = string
+  sensitive   = true
+
+  validation {
+    condition     = length(var.draw_secret) >= 32
+    error_message = "draw_secret must be at least 32 characters, so every container deals the same card of the day and accepts the same commitments and deck-state tokens."
+  }
+}
+
 variable "environment" {
   description = "Environment name (dev, staging, prod)"
   type        = string
@@ -55,7 +66,7 @@ variable "hosted_zone_name" {}
 variable "lambda_memory_size" {
   description = "Lambda function memory size in MB"
   type        = number
-  default     = 128
+  default     = 512
 }
 
 variable "lambda_timeout" {
@@ -75,3 +86,14 @@ variable "project_name" {
   type        = string
   default     = "tarot"
 }
+
+variable "receipt_signing_key" {
+  description = "Ed25519 key, e.g. from openssl genpkey -algorithm ed25519, as PEM PKCS #8 or a base64 seed, that every Lambda container signs draw receipts with"
+  type        = string
+  sensitive   = true
+
+  validation {
+    condition     = length(trimspace(var.receipt_signing_key)) > 0
+    error_message = "receipt_signing_key must be set, so every container signs receipts with the key published at GET /keys."
+  }
+}